@@ -0,0 +1,328 @@
+package patrol
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/karimsa/patrol/internal/auth"
+	"github.com/karimsa/patrol/internal/incident"
+	"github.com/karimsa/patrol/internal/silence"
+	"github.com/karimsa/patrol/internal/uptime"
+)
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(res http.ResponseWriter, status int, data interface{}) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	if err := json.NewEncoder(res).Encode(data); err != nil {
+		log.Printf("warn: Failed to write JSON response: %s", err)
+	}
+}
+
+// Verifies that the identity of the request has at least the given scope. If
+// it does not, an error response is written and false is returned.
+func (p *Patrol) requireScope(res http.ResponseWriter, req *http.Request, scope auth.Scope) bool {
+	id := auth.FromContext(req.Context())
+	if id.Scope >= scope {
+		return true
+	}
+	if !p.auth.Configured() {
+		writeJSON(res, http.StatusForbidden, apiError{"No API tokens or users are configured, modifications are disabled"})
+	} else if id.Anonymous() {
+		p.writeUnauthorized(res, req, "Authentication required")
+	} else {
+		writeJSON(res, http.StatusForbidden, apiError{fmt.Sprintf("This action requires the '%s' scope", scope)})
+	}
+	return false
+}
+
+type createSilenceRequest struct {
+	Group    string            `json:"group"`
+	Check    string            `json:"check"`
+	Labels   map[string]string `json:"labels"`
+	Comment  string            `json:"comment"`
+	Duration string            `json:"duration"`
+	EndsAt   time.Time         `json:"endsAt"`
+}
+
+type createAnnouncementRequest struct {
+	Title    string   `json:"title"`
+	Services []string `json:"services"`
+	Status   string   `json:"status"`
+	Message  string   `json:"message"`
+}
+
+type updateAnnouncementRequest struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+func (p *Patrol) serveAPI(res http.ResponseWriter, req *http.Request) {
+	switch {
+	case req.URL.Path == "/api/v1/acknowledge":
+		if req.Method != http.MethodPost && req.Method != http.MethodDelete {
+			writeJSON(res, http.StatusMethodNotAllowed, apiError{"Method not allowed"})
+			return
+		}
+		if !p.requireScope(res, req, auth.ScopeWrite) {
+			return
+		}
+		group, check := req.FormValue("group"), req.FormValue("check")
+		if group == "" || check == "" {
+			writeJSON(res, http.StatusBadRequest, apiError{"Both 'group' and 'check' are required"})
+			return
+		}
+
+		var ok bool
+		var err error
+		if req.Method == http.MethodPost {
+			ok, err = p.Acknowledge(group, check)
+		} else {
+			ok, err = p.silences.Unacknowledge(group, check)
+		}
+		if err != nil {
+			writeJSON(res, http.StatusInternalServerError, apiError{err.Error()})
+		} else if !ok && req.Method == http.MethodPost {
+			writeJSON(res, http.StatusNotFound, apiError{"Check is not currently unhealthy"})
+		} else if !ok {
+			writeJSON(res, http.StatusNotFound, apiError{"Check is not acknowledged"})
+		} else {
+			writeJSON(res, http.StatusOK, map[string]bool{"acknowledged": req.Method == http.MethodPost})
+		}
+
+	case req.URL.Path == "/api/v1/silences":
+		switch req.Method {
+		case http.MethodGet:
+			writeJSON(res, http.StatusOK, p.visibleSilences(req, p.silences.List()))
+
+		case http.MethodPost:
+			if !p.requireScope(res, req, auth.ScopeWrite) {
+				return
+			}
+			var body createSilenceRequest
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				writeJSON(res, http.StatusBadRequest, apiError{"Invalid request body: " + err.Error()})
+				return
+			}
+			if body.Duration != "" {
+				d, err := time.ParseDuration(body.Duration)
+				if err != nil {
+					writeJSON(res, http.StatusBadRequest, apiError{"Invalid duration: " + err.Error()})
+					return
+				}
+				body.EndsAt = time.Now().Add(d)
+			}
+			s, err := p.silences.Add(silence.Silence{
+				Group:   body.Group,
+				Check:   body.Check,
+				Labels:  body.Labels,
+				Comment: body.Comment,
+				EndsAt:  body.EndsAt,
+			})
+			if err != nil {
+				writeJSON(res, http.StatusBadRequest, apiError{err.Error()})
+				return
+			}
+			p.logger.Infof("Created silence: %s", s)
+			writeJSON(res, http.StatusCreated, s)
+
+		default:
+			writeJSON(res, http.StatusMethodNotAllowed, apiError{"Method not allowed"})
+		}
+
+	case strings.HasPrefix(req.URL.Path, "/api/v1/silences/"):
+		if req.Method != http.MethodDelete {
+			writeJSON(res, http.StatusMethodNotAllowed, apiError{"Method not allowed"})
+			return
+		}
+		if !p.requireScope(res, req, auth.ScopeWrite) {
+			return
+		}
+		id := strings.TrimPrefix(req.URL.Path, "/api/v1/silences/")
+		if ok, err := p.silences.Remove(id); err != nil {
+			writeJSON(res, http.StatusInternalServerError, apiError{err.Error()})
+		} else if !ok {
+			writeJSON(res, http.StatusNotFound, apiError{"No such silence"})
+		} else {
+			p.logger.Infof("Removed silence: %s", id)
+			writeJSON(res, http.StatusOK, map[string]bool{"removed": true})
+		}
+
+	case req.URL.Path == "/api/v1/uptime":
+		if req.Method != http.MethodGet {
+			writeJSON(res, http.StatusMethodNotAllowed, apiError{"Method not allowed"})
+			return
+		}
+		var windows []time.Duration
+		for _, str := range req.URL.Query()["window"] {
+			window, err := uptime.ParseWindow(str)
+			if err != nil {
+				writeJSON(res, http.StatusBadRequest, apiError{err.Error()})
+				return
+			}
+			windows = append(windows, window)
+		}
+
+		type groupResponse struct {
+			Service map[string]*float64 `json:"service"`
+			Covered map[string]float64  `json:"covered,omitempty"`
+			Checks  orderedObject       `json:"checks"`
+		}
+		// Services and checks are listed in the order of the config
+		groups := p.visibleUptime(req, windows)
+		groupNames := make([]string, 0, len(groups))
+		for groupName := range groups {
+			groupNames = append(groupNames, groupName)
+		}
+		data := make(orderedObject, 0, len(groups))
+		for _, groupName := range p.SortGroups(groupNames) {
+			group := groups[groupName]
+			checkNames := make([]string, 0, len(group.Checks))
+			for checkName := range group.Checks {
+				checkNames = append(checkNames, checkName)
+			}
+			g := groupResponse{
+				Service: uptimeJSON(group.Service),
+				Covered: coverageJSON(group.Service),
+				Checks:  make(orderedObject, 0, len(group.Checks)),
+			}
+			for _, checkName := range p.SortChecks(groupName, checkNames) {
+				g.Checks = append(g.Checks, objectField{checkName, uptimeJSON(group.Checks[checkName])})
+			}
+			data = append(data, objectField{groupName, g})
+		}
+		writeJSON(res, http.StatusOK, data)
+
+	case req.URL.Path == "/api/v1/incidents":
+		if req.Method != http.MethodGet {
+			writeJSON(res, http.StatusMethodNotAllowed, apiError{"Method not allowed"})
+			return
+		}
+		query := req.URL.Query()
+		incidents := p.visibleIncidents(req, p.incidents.List(query.Get("group"), query.Get("check")))
+		if status := query.Get("status"); status != "" {
+			filtered := make([]incident.Incident, 0, len(incidents))
+			for _, i := range incidents {
+				if (status == "resolved") == i.Resolved() {
+					filtered = append(filtered, i)
+				}
+			}
+			incidents = filtered
+		}
+		writeJSON(res, http.StatusOK, incidents)
+
+	case strings.HasPrefix(req.URL.Path, "/api/v1/incidents/"):
+		if req.Method != http.MethodGet {
+			writeJSON(res, http.StatusMethodNotAllowed, apiError{"Method not allowed"})
+			return
+		}
+		i, ok := p.incidents.Get(strings.TrimPrefix(req.URL.Path, "/api/v1/incidents/"))
+		if ok {
+			i, ok = p.visibleIncident(req, i)
+		}
+		if !ok {
+			writeJSON(res, http.StatusNotFound, apiError{"No such incident"})
+			return
+		}
+		writeJSON(res, http.StatusOK, i)
+
+	case req.URL.Path == "/api/v1/events":
+		p.serveEvents(res, req)
+
+	case req.URL.Path == "/api/v1/announcements":
+		switch req.Method {
+		case http.MethodGet:
+			if req.URL.Query().Get("all") == "true" {
+				writeJSON(res, http.StatusOK, p.visibleAnnouncements(req, p.announcements.List(time.Time{})))
+			} else {
+				writeJSON(res, http.StatusOK, p.visibleAnnouncements(req, p.Announcements()))
+			}
+
+		case http.MethodPost:
+			if !p.requireScope(res, req, auth.ScopeWrite) {
+				return
+			}
+			var body createAnnouncementRequest
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				writeJSON(res, http.StatusBadRequest, apiError{"Invalid request body: " + err.Error()})
+				return
+			}
+			for _, service := range body.Services {
+				if !p.hasGroup(service) {
+					writeJSON(res, http.StatusBadRequest, apiError{"Unknown service: " + service})
+					return
+				}
+			}
+			a, err := p.announcements.Create(body.Title, body.Services, body.Status, body.Message)
+			if err != nil {
+				writeJSON(res, http.StatusBadRequest, apiError{err.Error()})
+				return
+			}
+			p.logger.Infof("Created announcement: %s", a)
+			writeJSON(res, http.StatusCreated, a)
+
+		default:
+			writeJSON(res, http.StatusMethodNotAllowed, apiError{"Method not allowed"})
+		}
+
+	case strings.HasPrefix(req.URL.Path, "/api/v1/announcements/"):
+		id := strings.TrimPrefix(req.URL.Path, "/api/v1/announcements/")
+		switch req.Method {
+		case http.MethodGet:
+			a, ok := p.announcements.Get(id)
+			if ok {
+				a, ok = p.visibleAnnouncement(req, a)
+			}
+			if !ok {
+				writeJSON(res, http.StatusNotFound, apiError{"No such announcement"})
+				return
+			}
+			writeJSON(res, http.StatusOK, a)
+
+		case http.MethodPost:
+			if !p.requireScope(res, req, auth.ScopeWrite) {
+				return
+			}
+			var body updateAnnouncementRequest
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				writeJSON(res, http.StatusBadRequest, apiError{"Invalid request body: " + err.Error()})
+				return
+			}
+			a, ok, err := p.announcements.Update(id, body.Status, body.Message)
+			if err != nil {
+				writeJSON(res, http.StatusBadRequest, apiError{err.Error()})
+			} else if !ok {
+				writeJSON(res, http.StatusNotFound, apiError{"No such announcement"})
+			} else {
+				p.logger.Infof("Updated announcement: %s", a)
+				writeJSON(res, http.StatusOK, a)
+			}
+
+		case http.MethodDelete:
+			if !p.requireScope(res, req, auth.ScopeAdmin) {
+				return
+			}
+			if ok, err := p.announcements.Delete(id); err != nil {
+				writeJSON(res, http.StatusInternalServerError, apiError{err.Error()})
+			} else if !ok {
+				writeJSON(res, http.StatusNotFound, apiError{"No such announcement"})
+			} else {
+				p.logger.Infof("Removed announcement: %s", id)
+				writeJSON(res, http.StatusOK, map[string]bool{"removed": true})
+			}
+
+		default:
+			writeJSON(res, http.StatusMethodNotAllowed, apiError{"Method not allowed"})
+		}
+
+	default:
+		writeJSON(res, http.StatusNotFound, apiError{"Not found"})
+	}
+}
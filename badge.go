@@ -0,0 +1,149 @@
+package patrol
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/karimsa/patrol/internal/badge"
+	"github.com/karimsa/patrol/internal/history"
+	"github.com/karimsa/patrol/internal/uptime"
+)
+
+// Badges are cached for the shortest interval of the checks they cover, but
+// never less than this.
+const minBadgeMaxAge = 10 * time.Second
+
+// Severity of each status when summarizing the status of a service.
+var statusSeverity = map[string]int{
+	"healthy":     1,
+	"recovered":   2,
+	"maintenance": 3,
+	"unhealthy":   4,
+}
+
+// Returns the status of the latest item of each check that is most severe.
+func worstStatus(checks map[string][]history.Item) string {
+	worst := ""
+	for _, items := range checks {
+		if len(items) > 0 && statusSeverity[items[0].Status] > statusSeverity[worst] {
+			worst = items[0].Status
+		}
+	}
+	return worst
+}
+
+func (p *Patrol) serveBadge(res http.ResponseWriter, req *http.Request) {
+	segments := pathSegments(req)
+	if len(segments) < 2 || len(segments) > 3 || !strings.HasSuffix(segments[len(segments)-1], ".svg") {
+		res.WriteHeader(http.StatusNotFound)
+		res.Write([]byte("Badge not found"))
+		return
+	}
+	segments[len(segments)-1] = strings.TrimSuffix(segments[len(segments)-1], ".svg")
+	group, check := segments[1], ""
+	if len(segments) == 3 {
+		check = segments[2]
+	}
+
+	checks := make(map[string][]history.Item)
+	maxAge := time.Duration(0)
+	for _, c := range p.checkers {
+		if c.Group == group && (check == "" || c.Name == check) && p.canSee(req, c.Group, c.Name) {
+			checks[c.Name] = p.History.GetGroupItems(c.Group, c.Name)
+			if maxAge == 0 || c.Interval < maxAge {
+				maxAge = c.Interval
+			}
+		}
+	}
+	if len(checks) == 0 {
+		res.WriteHeader(http.StatusNotFound)
+		res.Write([]byte("Badge not found"))
+		return
+	}
+	if maxAge < minBadgeMaxAge {
+		maxAge = minBadgeMaxAge
+	}
+
+	query := req.URL.Query()
+	label := query.Get("label")
+	if label == "" {
+		label = group
+		if check != "" {
+			label = check
+		}
+	}
+
+	var message, color string
+	switch query.Get("type") {
+	case "", "status":
+		message = worstStatus(checks)
+		color = badge.StatusColor(message)
+		if message == "" {
+			message = "pending"
+		}
+
+	case "uptime":
+		window := p.uptimeWindows[0]
+		if str := query.Get("window"); str != "" {
+			var err error
+			if window, err = uptime.ParseWindow(str); err != nil {
+				res.WriteHeader(http.StatusBadRequest)
+				res.Write([]byte(err.Error()))
+				return
+			}
+		}
+
+		var result uptime.Result
+		if check == "" {
+			result = uptime.ForService(checks, window, time.Now())
+		} else {
+			result = uptime.ForCheck(checks[check], window, time.Now())
+		}
+		if query.Get("label") == "" {
+			label = fmt.Sprintf("%s uptime (%s)", label, uptime.FormatWindow(window))
+		}
+		message, color = "no data", badge.ColorGrey
+		if result.Known() {
+			message = fmt.Sprintf("%.2f%%", result.Percent())
+			if result.Partial() {
+				message += fmt.Sprintf(" (%s of data)", uptime.FormatCovered(result.Covered))
+			}
+			color = badge.UptimeColor(result.Percent())
+		}
+
+	case "metric":
+		items := checks[check]
+		if check == "" || (len(items) > 0 && items[0].Type != "metric") {
+			res.WriteHeader(http.StatusBadRequest)
+			res.Write([]byte("Metric badges are only available for metric checks"))
+			return
+		}
+		message, color = "pending", badge.ColorGrey
+		if len(items) > 0 {
+			message = strings.TrimSpace(fmt.Sprintf("%.2f %s", items[0].Metric, items[0].MetricUnit))
+			color = badge.StatusColor(items[0].Status)
+		}
+
+	default:
+		res.WriteHeader(http.StatusBadRequest)
+		res.Write([]byte("Badge type must be one of: status, uptime, metric"))
+		return
+	}
+
+	svg := badge.Render(label, message, color)
+	hash := sha1.Sum(svg)
+	etag := `"` + hex.EncodeToString(hash[:8]) + `"`
+
+	res.Header().Set("Content-Type", "image/svg+xml")
+	res.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	res.Header().Set("ETag", etag)
+	if req.Header.Get("If-None-Match") == etag {
+		res.WriteHeader(http.StatusNotModified)
+		return
+	}
+	res.Write(svg)
+}
@@ -0,0 +1,212 @@
+package patrol
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+
+	"github.com/karimsa/patrol/internal/history"
+)
+
+var (
+	gridMajorStyle = chart.Style{
+		Show:        true,
+		StrokeWidth: 1.5,
+		StrokeColor: drawing.Color{
+			A: 90,
+		},
+	}
+	gridMinorStyle = chart.Style{
+		Show:        true,
+		StrokeWidth: 1.0,
+		StrokeColor: drawing.Color{
+			A: 50,
+		},
+	}
+	seriesStyle = chart.Style{
+		Show:        true,
+		FontColor:   drawing.ColorBlack,
+		StrokeWidth: 3,
+	}
+	metricChartDefaults = chart.Chart{
+		XAxis: chart.XAxis{
+			Style:          chart.StyleShow(),
+			GridMajorStyle: gridMajorStyle,
+			GridMinorStyle: gridMinorStyle,
+		},
+		YAxis: chart.YAxis{
+			Style:          chart.StyleShow(),
+			GridMajorStyle: gridMajorStyle,
+			GridMinorStyle: gridMinorStyle,
+		},
+	}
+)
+
+// Summary of the results of a metric check, shown next to its chart.
+type chartResult struct {
+	Min, Max, Avg float64
+	Error         string
+}
+
+func chartSummary(items []history.Item) chartResult {
+	if len(items) < 1 {
+		return chartResult{Error: "Data pending"}
+	}
+
+	res := chartResult{
+		Min: items[0].Metric,
+		Max: items[0].Metric,
+	}
+	for _, item := range items {
+		if res.Min > item.Metric {
+			res.Min = item.Metric
+		}
+		if res.Max < item.Metric {
+			res.Max = item.Metric
+		}
+		res.Avg += item.Metric
+	}
+	res.Avg /= float64(len(items))
+	return res
+}
+
+// Renders the results of a metric check as an SVG chart.
+func renderChart(items []history.Item) ([]byte, error) {
+	summary := chartSummary(items)
+	xValues := make([]time.Time, len(items))
+	yValues := make([]float64, len(items))
+	for i, item := range items {
+		xValues[i] = item.CreatedAt
+		yValues[i] = item.Metric
+	}
+
+	c := metricChartDefaults
+	c.Series = []chart.Series{
+		chart.TimeSeries{
+			XValues: xValues,
+			YValues: yValues,
+			Style:   seriesStyle,
+		},
+	}
+
+	// go-chart cannot draw constant functions
+	if summary.Min == summary.Max {
+		c.YAxis.Range = &chart.ContinuousRange{
+			Min: summary.Min - 1,
+			Max: summary.Max + 1,
+		}
+	}
+	if len(items) == 1 {
+		c.XAxis.Range = &chart.ContinuousRange{
+			Min: float64(items[0].CreatedAt.UnixNano() - int64(24*time.Hour)),
+			Max: float64(items[0].CreatedAt.UnixNano() + int64(24*time.Hour)),
+		}
+	} else if len(items) == 0 {
+		c.XAxis.Range = &chart.ContinuousRange{
+			Min: 0,
+			Max: 1,
+		}
+	}
+
+	buffer := bytes.Buffer{}
+	if err := c.Render(chart.SVG, &buffer); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Returns the ETag of the chart of the given items, which changes whenever
+// a result is appended to the history of the check (or drops out of it).
+func chartETag(rangeName string, items []history.Item) string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%s|%d", rangeName, len(items))
+	if len(items) > 0 {
+		newest, oldest := items[0], items[len(items)-1]
+		fmt.Fprintf(hash, "|%s|%d|%s|%d", newest.ID, newest.CreatedAt.UnixNano(), oldest.ID, oldest.CreatedAt.UnixNano())
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:8]) + `"`
+}
+
+type renderedChart struct {
+	etag string
+	svg  []byte
+}
+
+// Keeps the latest chart of each metric check (and range), so that charts
+// are only rendered again once new results come in.
+type chartCache struct {
+	mux    sync.Mutex
+	charts map[string]renderedChart
+}
+
+func newChartCache() *chartCache {
+	return &chartCache{charts: make(map[string]renderedChart)}
+}
+
+// Returns the chart with the given ETag, rendering it if the cached chart is
+// out of date.
+func (cache *chartCache) get(key, etag string, items []history.Item) ([]byte, error) {
+	cache.mux.Lock()
+	cached, ok := cache.charts[key]
+	cache.mux.Unlock()
+	if ok && cached.etag == etag {
+		return cached.svg, nil
+	}
+
+	svg, err := renderChart(items)
+	if err != nil {
+		return nil, err
+	}
+	cache.mux.Lock()
+	cache.charts[key] = renderedChart{etag: etag, svg: svg}
+	cache.mux.Unlock()
+	return svg, nil
+}
+
+func (p *Patrol) serveChart(res http.ResponseWriter, req *http.Request) {
+	segments := pathSegments(req)
+	if len(segments) != 3 || !strings.HasSuffix(segments[2], ".svg") {
+		res.WriteHeader(http.StatusNotFound)
+		res.Write([]byte("Chart not found"))
+		return
+	}
+	group, check := segments[1], strings.TrimSuffix(segments[2], ".svg")
+
+	items := p.History.GetGroupItems(group, check)
+	rangeName := req.URL.Query().Get("range")
+	if r, ok := findRange(rangeName); ok {
+		items = itemsWithin(items, r, time.Now())
+	} else {
+		rangeName = ""
+	}
+	if len(items) == 0 || items[0].Type != "metric" || !p.canSee(req, group, check) {
+		res.WriteHeader(http.StatusNotFound)
+		res.Write([]byte("Chart not found"))
+		return
+	}
+
+	etag := chartETag(rangeName, items)
+	res.Header().Set("Content-Type", "image/svg+xml")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("ETag", etag)
+	if req.Header.Get("If-None-Match") == etag {
+		res.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	svg, err := p.charts.get(group+"\x00"+check+"\x00"+rangeName, etag, items)
+	if err != nil {
+		res.WriteHeader(http.StatusInternalServerError)
+		res.Write([]byte(fmt.Sprintf("Failed to render chart: %s", err)))
+		return
+	}
+	res.Write(svg)
+}
@@ -0,0 +1,121 @@
+package patrol
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karimsa/patrol/internal/checker"
+	"github.com/karimsa/patrol/internal/history"
+)
+
+func TestCharts(t *testing.T) {
+	removeTestDB("charts-test.db")
+	defer removeTestDB("charts-test.db")
+	historyFile, err := history.New(history.NewOptions{
+		File: "charts-test.db",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer historyFile.Close()
+
+	p, err := New(CreatePatrolOptions{
+		Checkers: []*checker.Checker{
+			checker.New(&checker.Checker{
+				Group:    "api",
+				Name:     "Latency",
+				Type:     "metric",
+				Cmd:      "echo 1",
+				History:  historyFile,
+				Interval: 1 * time.Minute,
+			}),
+			checker.New(&checker.Checker{
+				Group:    "api",
+				Name:     "Status",
+				Cmd:      "true",
+				History:  historyFile,
+				Interval: 1 * time.Minute,
+			}),
+			checker.New(&checker.Checker{
+				Group:      "api",
+				Name:       "Queue size",
+				Type:       "metric",
+				Cmd:        "echo 1",
+				History:    historyFile,
+				Interval:   1 * time.Minute,
+				Visibility: "internal",
+			}),
+		},
+		APIToken: "secret",
+	}, historyFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, item := range []history.Item{
+		{Group: "api", Name: "Latency", Type: "metric", Status: "healthy", Metric: 10},
+		{Group: "api", Name: "Latency", Type: "metric", Status: "healthy", Metric: 20},
+		{Group: "api", Name: "Queue size", Type: "metric", Status: "healthy", Metric: 5},
+		{Group: "api", Name: "Status", Type: "boolean", Status: "healthy"},
+	} {
+		if _, err := historyFile.Append(item); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	request := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		res := httptest.NewRecorder()
+		p.ServeHTTP(res, req)
+		return res
+	}
+
+	res := request("/charts/api/Latency.svg", "")
+	etag := res.Header().Get("ETag")
+	if res.Code != http.StatusOK || res.Header().Get("Content-Type") != "image/svg+xml" || etag == "" || !strings.Contains(res.Body.String(), "<svg") {
+		t.Error(fmt.Errorf("Expected chart to be served with an ETag, got: %d %s", res.Code, res.Header()))
+		return
+	}
+	if res := request("/charts/api/Latency.svg", etag); res.Code != http.StatusNotModified {
+		t.Error(fmt.Errorf("Expected unchanged chart to return 304, got: %d", res.Code))
+	}
+	if res := request("/charts/api/Latency.svg?range=24h", etag); res.Code != http.StatusOK || res.Header().Get("ETag") == etag {
+		t.Error(fmt.Errorf("Expected chart of a range to have its own ETag, got: %d", res.Code))
+	}
+
+	items := historyFile.GetGroupItems("api", "Latency")
+	key := "api\x00Latency\x00"
+	first, _ := p.charts.get(key, etag, items)
+	second, _ := p.charts.get(key, etag, items)
+	if &first[0] != &second[0] {
+		t.Error(fmt.Errorf("Expected chart to be rendered once for the same results"))
+	}
+
+	if _, err := historyFile.Append(history.Item{Group: "api", Name: "Latency", Type: "metric", Status: "healthy", Metric: 30}); err != nil {
+		t.Error(err)
+		return
+	}
+	if res := request("/charts/api/Latency.svg", etag); res.Code != http.StatusOK || res.Header().Get("ETag") == etag {
+		t.Error(fmt.Errorf("Expected chart to change after a new result, got: %d", res.Code))
+	}
+
+	for _, path := range []string{
+		"/charts/api/Status.svg",
+		"/charts/api/Missing.svg",
+		"/charts/api/Queue%20size.svg",
+		"/charts/api/Latency",
+	} {
+		if res := request(path, ""); res.Code != http.StatusNotFound {
+			t.Error(fmt.Errorf("Expected %s to return 404, got: %d", path, res.Code))
+		}
+	}
+}
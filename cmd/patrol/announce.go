@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+type announcementResponse struct {
+	ID       string
+	Title    string
+	Services []string
+	Status   string
+	Updates  []struct {
+		Status    string
+		Message   string
+		CreatedAt time.Time
+	}
+	UpdatedAt time.Time
+}
+
+func (a announcementResponse) String() string {
+	str := fmt.Sprintf("%s\t%s title=%q services=%q updated=%s", a.ID, a.Status, a.Title, strings.Join(a.Services, ","), a.UpdatedAt.Format(time.RFC3339))
+	for _, update := range a.Updates {
+		str += fmt.Sprintf("\n\t%s\t%s: %s", update.CreatedAt.Format(time.RFC3339), update.Status, update.Message)
+	}
+	return str
+}
+
+var statusFlag = &cli.StringFlag{
+	Name:  "status",
+	Usage: "Status of the announcement: investigating, identified, monitoring, or resolved",
+}
+
+var messageFlag = &cli.StringFlag{
+	Name:    "message",
+	Aliases: []string{"m"},
+	Usage:   "Message to post with the update",
+}
+
+var cmdAnnounce = &cli.Command{
+	Name:  "announce",
+	Usage: "Manage announcements on the status page of a running patrol instance.",
+	Subcommands: []*cli.Command{
+		{
+			Name:      "create",
+			Usage:     "Post a new announcement.",
+			ArgsUsage: "TITLE",
+			Flags: []cli.Flag{
+				urlFlag,
+				tokenFlag,
+				statusFlag,
+				messageFlag,
+				&cli.StringSliceFlag{
+					Name:  "service",
+					Usage: "Name of an affected service, can be given multiple times",
+				},
+			},
+			Action: func(ctx *cli.Context) error {
+				if ctx.NArg() != 1 {
+					return fmt.Errorf("Exactly one title must be given")
+				}
+				var a announcementResponse
+				if err := apiRequest(ctx, http.MethodPost, "/api/v1/announcements", map[string]interface{}{
+					"title":    ctx.Args().First(),
+					"services": ctx.StringSlice("service"),
+					"status":   ctx.String("status"),
+					"message":  ctx.String("message"),
+				}, &a); err != nil {
+					return err
+				}
+				fmt.Printf("%s\n", a)
+				return nil
+			},
+		},
+		{
+			Name:      "update",
+			Usage:     "Post an update to an existing announcement.",
+			ArgsUsage: "ID",
+			Flags: []cli.Flag{
+				urlFlag,
+				tokenFlag,
+				statusFlag,
+				messageFlag,
+			},
+			Action: func(ctx *cli.Context) error {
+				if ctx.NArg() != 1 {
+					return fmt.Errorf("Exactly one announcement ID must be given")
+				}
+				var a announcementResponse
+				if err := apiRequest(ctx, http.MethodPost, "/api/v1/announcements/"+url.PathEscape(ctx.Args().First()), map[string]interface{}{
+					"status":  ctx.String("status"),
+					"message": ctx.String("message"),
+				}, &a); err != nil {
+					return err
+				}
+				fmt.Printf("%s\n", a)
+				return nil
+			},
+		},
+		{
+			Name:    "list",
+			Aliases: []string{"ls"},
+			Usage:   "List announcements shown on the status page.",
+			Flags: []cli.Flag{
+				urlFlag,
+				tokenFlag,
+				&cli.BoolFlag{
+					Name:  "all",
+					Usage: "Include announcements that were resolved more than a day ago",
+				},
+			},
+			Action: func(ctx *cli.Context) error {
+				path := "/api/v1/announcements"
+				if ctx.Bool("all") {
+					path += "?all=true"
+				}
+				var announcements []announcementResponse
+				if err := apiRequest(ctx, http.MethodGet, path, nil, &announcements); err != nil {
+					return err
+				}
+				for _, a := range announcements {
+					fmt.Printf("%s\n", a)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "remove",
+			Aliases:   []string{"rm"},
+			Usage:     "Remove an announcement entirely.",
+			ArgsUsage: "ID",
+			Flags: []cli.Flag{
+				urlFlag,
+				tokenFlag,
+			},
+			Action: func(ctx *cli.Context) error {
+				if ctx.NArg() != 1 {
+					return fmt.Errorf("Exactly one announcement ID must be given")
+				}
+				return apiRequest(ctx, http.MethodDelete, "/api/v1/announcements/"+url.PathEscape(ctx.Args().First()), nil, nil)
+			},
+		},
+	},
+}
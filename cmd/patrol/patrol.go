@@ -1,14 +1,19 @@
 package main
 
 import (
-	"encoding/json"
+	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
+	"time"
 
 	"github.com/karimsa/patrol"
+	"github.com/karimsa/patrol/internal/uptime"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -18,6 +23,15 @@ var (
 		TakesFile: true,
 		Required:  true,
 	}
+	configDirFlag = &cli.PathFlag{
+		Name:      "config-dir",
+		Usage:     "Path to a directory of config files (such as conf.d) defining additional services",
+		TakesFile: true,
+	}
+	showSecretsFlag = &cli.BoolFlag{
+		Name:  "show-secrets",
+		Usage: "Log the entire config, including secrets, instead of a summary. Only meant for local debugging.",
+	}
 )
 
 var cmdRun = &cli.Command{
@@ -25,14 +39,16 @@ var cmdRun = &cli.Command{
 	Usage: "Run statuspage using given configuration file.",
 	Flags: []cli.Flag{
 		configFlag,
+		configDirFlag,
+		showSecretsFlag,
 	},
 	Action: func(ctx *cli.Context) error {
-		p, config, err := patrol.FromConfigFile(ctx.String("config"), nil)
+		p, config, err := patrol.FromConfigFiles(ctx.String("config"), ctx.String("config-dir"), nil)
 		if err != nil {
 			return err
 		}
 
-		cs, err := json.MarshalIndent(config, "", "\t")
+		cs, err := config.LogJSON(ctx.Bool("show-secrets"))
 		if err != nil {
 			return err
 		}
@@ -49,25 +65,56 @@ var cmdRun = &cli.Command{
 	},
 }
 
+var cmdHashPassword = &cli.Command{
+	Name:  "hash-password",
+	Usage: "Hash a password read from stdin, for use as the 'passwordHash' of a user.",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "cost",
+			Usage: "bcrypt cost factor",
+			Value: bcrypt.DefaultCost,
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		password = strings.TrimRight(password, "\r\n")
+		if password == "" {
+			return fmt.Errorf("Password cannot be empty")
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), ctx.Int("cost"))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", hash)
+		return nil
+	},
+}
+
 var cmdCheckConfig = &cli.Command{
 	Name:    "check-config",
 	Aliases: []string{"c"},
 	Usage:   "Validate statuspage configuration file. Data file will be created if it does not exist and will be compacted if it already exists.",
 	Flags: []cli.Flag{
 		configFlag,
+		configDirFlag,
 		&cli.BoolFlag{
 			Name:  "no-compact",
 			Usage: "If specified, compaction is skipped.",
 			Value: false,
 		},
+		showSecretsFlag,
 	},
 	Action: func(ctx *cli.Context) error {
-		p, config, err := patrol.FromConfigFile(ctx.String("config"), nil)
+		p, config, err := patrol.FromConfigFiles(ctx.String("config"), ctx.String("config-dir"), nil)
 		if err != nil {
 			return err
 		}
 
-		cs, err := json.MarshalIndent(config, "", "\t")
+		cs, err := config.LogJSON(ctx.Bool("show-secrets"))
 		if err != nil {
 			return err
 		}
@@ -101,6 +148,7 @@ var cmdList = &cli.Command{
 	Usage:   "List records from data file.",
 	Flags: []cli.Flag{
 		configFlag,
+		configDirFlag,
 		&cli.StringSliceFlag{
 			Name:  "group",
 			Usage: "Filter by group name",
@@ -124,7 +172,7 @@ var cmdList = &cli.Command{
 		},
 	},
 	Action: func(ctx *cli.Context) error {
-		p, _, err := patrol.FromConfigFile(ctx.String("config"), nil)
+		p, _, err := patrol.FromConfigFiles(ctx.String("config"), ctx.String("config-dir"), nil)
 		if err != nil {
 			return err
 		}
@@ -135,14 +183,13 @@ var cmdList = &cli.Command{
 		statusFilter := ctx.StringSlice("status")
 		maxMatches := ctx.Int("count")
 
-		data := p.History.GetData()
 		numMatches := 0
 	outer:
-		for groupName, group := range data {
-			if sliceContains(groupFilter, groupName) {
-				for checkName, items := range group {
-					if sliceContains(checkFilter, checkName) {
-						for _, item := range items {
+		for _, group := range p.GroupedData() {
+			if sliceContains(groupFilter, group.Name) {
+				for _, check := range group.Checks {
+					if sliceContains(checkFilter, check.Name) {
+						for _, item := range check.Items {
 							if sliceContains(typeFilter, item.Type) && sliceContains(statusFilter, item.Status) {
 								fmt.Printf("-\n%s\n", item)
 								numMatches++
@@ -163,6 +210,107 @@ var cmdList = &cli.Command{
 	},
 }
 
+var cmdUptime = &cli.Command{
+	Name:  "uptime",
+	Usage: "Print uptime percentages of each service and check from data file.",
+	Flags: []cli.Flag{
+		configFlag,
+		configDirFlag,
+		&cli.StringSliceFlag{
+			Name:    "window",
+			Aliases: []string{"w"},
+			Usage:   "Window to calculate uptime over, such as 24h or 7d (defaults to configured windows)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "group",
+			Usage: "Filter by group name",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		p, _, err := patrol.FromConfigFiles(ctx.String("config"), ctx.String("config-dir"), nil)
+		if err != nil {
+			return err
+		}
+		defer p.Close()
+
+		var windows []time.Duration
+		for _, str := range ctx.StringSlice("window") {
+			window, err := uptime.ParseWindow(str)
+			if err != nil {
+				return err
+			}
+			windows = append(windows, window)
+		}
+
+		groupFilter := ctx.StringSlice("group")
+		groups := p.Uptime(windows)
+		groupNames := make([]string, 0, len(groups))
+		for groupName := range groups {
+			groupNames = append(groupNames, groupName)
+		}
+		for _, groupName := range p.SortGroups(groupNames) {
+			if sliceContains(groupFilter, groupName) {
+				group := groups[groupName]
+				fmt.Printf("%s\t%s\n", groupName, group.Service)
+				checkNames := make([]string, 0, len(group.Checks))
+				for checkName := range group.Checks {
+					checkNames = append(checkNames, checkName)
+				}
+				for _, checkName := range p.SortChecks(groupName, checkNames) {
+					fmt.Printf("\t%s\t%s\n", checkName, group.Checks[checkName])
+				}
+			}
+		}
+		return nil
+	},
+}
+
+var cmdIncidents = &cli.Command{
+	Name:  "incidents",
+	Usage: "List incidents derived from data file.",
+	Flags: []cli.Flag{
+		configFlag,
+		configDirFlag,
+		&cli.StringFlag{
+			Name:  "group",
+			Usage: "Filter by group name",
+		},
+		&cli.StringFlag{
+			Name:  "check",
+			Usage: "Filter by check name",
+		},
+		&cli.BoolFlag{
+			Name:  "ongoing",
+			Usage: "Only list incidents that have not been resolved",
+		},
+		&cli.IntFlag{
+			Name:    "count",
+			Aliases: []string{"c"},
+			Usage:   "Max number of incidents to print",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		p, _, err := patrol.FromConfigFiles(ctx.String("config"), ctx.String("config-dir"), nil)
+		if err != nil {
+			return err
+		}
+		defer p.Close()
+
+		numMatches := 0
+		for _, i := range p.Incidents(ctx.String("group"), ctx.String("check")) {
+			if ctx.Bool("ongoing") && i.Resolved() {
+				continue
+			}
+			fmt.Printf("%s\n", i)
+			numMatches++
+			if numMatches == ctx.Int("count") {
+				break
+			}
+		}
+		return nil
+	},
+}
+
 func main() {
 	app := &cli.App{
 		Name:  "patrol",
@@ -171,6 +319,11 @@ func main() {
 			cmdCheckConfig,
 			cmdRun,
 			cmdList,
+			cmdSilence,
+			cmdAnnounce,
+			cmdHashPassword,
+			cmdUptime,
+			cmdIncidents,
 		},
 		Authors: []*cli.Author{
 			&cli.Author{
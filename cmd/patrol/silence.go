@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	urlFlag = &cli.StringFlag{
+		Name:    "url",
+		Usage:   "Base URL of the running patrol instance",
+		Value:   "http://localhost:8080",
+		EnvVars: []string{"PATROL_URL"},
+	}
+	tokenFlag = &cli.StringFlag{
+		Name:    "token",
+		Usage:   "API token configured for the patrol instance",
+		EnvVars: []string{"PATROL_API_TOKEN"},
+	}
+	groupFlag = &cli.StringFlag{
+		Name:  "group",
+		Usage: "Name of the group (service)",
+	}
+	checkFlag = &cli.StringFlag{
+		Name:  "check",
+		Usage: "Name of the check",
+	}
+)
+
+// Sends a request to the patrol API and decodes the JSON response into 'out'.
+func apiRequest(ctx *cli.Context, method, path string, body interface{}, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		buffer, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(buffer)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(ctx.String("url"), "/")+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if token := ctx.String("token"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			return fmt.Errorf("Request failed with status: %d", res.StatusCode)
+		}
+		return fmt.Errorf("Request failed with status %d: %s", res.StatusCode, apiErr.Error)
+	}
+	if out != nil {
+		return json.NewDecoder(res.Body).Decode(out)
+	}
+	return nil
+}
+
+type silenceResponse struct {
+	ID      string
+	Group   string
+	Check   string
+	Labels  map[string]string
+	Comment string
+	EndsAt  time.Time
+}
+
+func (s silenceResponse) String() string {
+	return fmt.Sprintf("%s\tgroup=%q check=%q labels=%v ends=%s comment=%q", s.ID, s.Group, s.Check, s.Labels, s.EndsAt.Format(time.RFC3339), s.Comment)
+}
+
+func acknowledgeAction(method string) cli.ActionFunc {
+	return func(ctx *cli.Context) error {
+		if ctx.String("group") == "" || ctx.String("check") == "" {
+			return fmt.Errorf("Both --group and --check are required")
+		}
+		query := url.Values{}
+		query.Set("group", ctx.String("group"))
+		query.Set("check", ctx.String("check"))
+		return apiRequest(ctx, method, "/api/v1/acknowledge?"+query.Encode(), nil, nil)
+	}
+}
+
+var cmdSilence = &cli.Command{
+	Name:  "silence",
+	Usage: "Manage silences and acknowledgements on a running patrol instance.",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "add",
+			Usage: "Silence notifications for matching checks.",
+			Flags: []cli.Flag{
+				urlFlag,
+				tokenFlag,
+				groupFlag,
+				checkFlag,
+				&cli.StringSliceFlag{
+					Name:  "label",
+					Usage: "Label matcher in the form key=value",
+				},
+				&cli.DurationFlag{
+					Name:     "duration",
+					Aliases:  []string{"d"},
+					Usage:    "How long the silence should last",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:  "comment",
+					Usage: "Reason for the silence",
+				},
+			},
+			Action: func(ctx *cli.Context) error {
+				labels := make(map[string]string)
+				for _, label := range ctx.StringSlice("label") {
+					parts := strings.SplitN(label, "=", 2)
+					if len(parts) != 2 {
+						return fmt.Errorf("Invalid label matcher: '%s'", label)
+					}
+					labels[parts[0]] = parts[1]
+				}
+				if ctx.String("group") == "" && ctx.String("check") == "" && len(labels) == 0 {
+					return fmt.Errorf("At least one of --group, --check, or --label must be given")
+				}
+
+				var s silenceResponse
+				if err := apiRequest(ctx, http.MethodPost, "/api/v1/silences", map[string]interface{}{
+					"group":    ctx.String("group"),
+					"check":    ctx.String("check"),
+					"labels":   labels,
+					"comment":  ctx.String("comment"),
+					"duration": ctx.Duration("duration").String(),
+				}, &s); err != nil {
+					return err
+				}
+				fmt.Printf("%s\n", s)
+				return nil
+			},
+		},
+		{
+			Name:    "list",
+			Aliases: []string{"ls"},
+			Usage:   "List active silences.",
+			Flags: []cli.Flag{
+				urlFlag,
+				tokenFlag,
+			},
+			Action: func(ctx *cli.Context) error {
+				var silences []silenceResponse
+				if err := apiRequest(ctx, http.MethodGet, "/api/v1/silences", nil, &silences); err != nil {
+					return err
+				}
+				for _, s := range silences {
+					fmt.Printf("%s\n", s)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "remove",
+			Aliases:   []string{"rm"},
+			Usage:     "Remove a silence before it expires.",
+			ArgsUsage: "ID",
+			Flags: []cli.Flag{
+				urlFlag,
+				tokenFlag,
+			},
+			Action: func(ctx *cli.Context) error {
+				if ctx.NArg() != 1 {
+					return fmt.Errorf("Exactly one silence ID must be given")
+				}
+				return apiRequest(ctx, http.MethodDelete, "/api/v1/silences/"+url.PathEscape(ctx.Args().First()), nil, nil)
+			},
+		},
+		{
+			Name:  "ack",
+			Usage: "Acknowledge the current failure of a check.",
+			Flags: []cli.Flag{
+				urlFlag,
+				tokenFlag,
+				groupFlag,
+				checkFlag,
+			},
+			Action: acknowledgeAction(http.MethodPost),
+		},
+		{
+			Name:  "unack",
+			Usage: "Clear the acknowledgement of a check.",
+			Flags: []cli.Flag{
+				urlFlag,
+				tokenFlag,
+				groupFlag,
+				checkFlag,
+			},
+			Action: acknowledgeAction(http.MethodDelete),
+		},
+	},
+}
@@ -3,13 +3,19 @@ package patrol
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/karimsa/patrol/internal/auth"
 	"github.com/karimsa/patrol/internal/checker"
 	"github.com/karimsa/patrol/internal/history"
 	"github.com/karimsa/patrol/internal/logger"
-	"gopkg.in/yaml.v2"
+	"github.com/karimsa/patrol/internal/maintenance"
+	"github.com/karimsa/patrol/internal/uptime"
 )
 
 type checkCmd string
@@ -34,49 +40,279 @@ func (cmd *checkCmd) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
-type configRaw struct {
-	Name     string
-	Port     int
-	HTTPS    PatrolHttpsOptions `yaml:"https"`
-	DB       string             `yaml:"db"`
-	LogLevel string             `yaml:"logLevel"`
-	Compact  history.CompactOptions
-	Services map[string]struct {
-		Checks []struct {
-			Name          string
-			Interval      duration
-			Timeout       duration
-			Cmd           checkCmd
-			Type          string
-			MetricUnit    string        `yaml:"unit"`
-			MaxRetries    *int          `yaml:"maxRetries"`
-			RetryInterval time.Duration `yaml:"retryInterval"`
-		}
-
-		OnFailure   []*singleNotificationConfig `yaml:"on_failure"`
-		OnRecovered []*singleNotificationConfig `yaml:"on_recovered"`
-		OnSuccess   []*singleNotificationConfig `yaml:"on_success"`
+type maintenanceConfig struct {
+	Start    string
+	End      string
+	Cron     string
+	Duration duration
+	Comment  string
+}
+
+func (mc maintenanceConfig) window() (w maintenance.Window, err error) {
+	w.Duration = mc.Duration.duration()
+	w.Comment = mc.Comment
+	if mc.Cron != "" {
+		if w.Schedule, err = maintenance.ParseSchedule(mc.Cron); err != nil {
+			return
+		}
+	}
+	if mc.Start != "" {
+		if w.Start, err = time.Parse(time.RFC3339, mc.Start); err != nil {
+			return
+		}
+	}
+	if mc.End != "" {
+		if w.End, err = time.Parse(time.RFC3339, mc.End); err != nil {
+			return
+		}
+	}
+	err = w.Validate()
+	return
+}
+
+func parseMaintenanceWindows(configs []maintenanceConfig) (maintenance.Windows, error) {
+	windows := make(maintenance.Windows, len(configs))
+	for idx, mc := range configs {
+		w, err := mc.window()
+		if err != nil {
+			return nil, fmt.Errorf("%d-th maintenance window is invalid: %s", idx, err)
+		}
+		windows[idx] = w
 	}
+	return windows, nil
+}
+
+func validateVisibility(visibility string) error {
+	if visibility != "" && visibility != "public" && visibility != "internal" {
+		return fmt.Errorf("must be either 'public' or 'internal', got '%s'", visibility)
+	}
+	return nil
+}
+
+type authConfig struct {
+	Users []struct {
+		Username     string
+		PasswordHash string `yaml:"passwordHash"`
+		Scope        string
+	}
+	Tokens []struct {
+		Name  string
+		Token string
+		Scope string
+	}
+	Proxy *struct {
+		Header         string
+		TrustedProxies []string `yaml:"trustedProxies"`
+		Scope          string
+	}
+	Anonymous string
+}
+
+// Parses a list of networks in CIDR notation. Bare IP addresses are taken
+// to be networks containing only that address.
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			if strings.Contains(cidr, ":") {
+				cidr += "/128"
+			} else {
+				cidr += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Scopes default to read access when they are not specified.
+func parseScope(str string) (auth.Scope, error) {
+	if str == "" {
+		return auth.ScopeRead, nil
+	}
+	return auth.ParseScope(str)
+}
+
+func (ac authConfig) options() (opts auth.Options, err error) {
+	for _, u := range ac.Users {
+		user := auth.User{Username: u.Username, PasswordHash: u.PasswordHash}
+		if user.Scope, err = parseScope(u.Scope); err != nil {
+			return opts, fmt.Errorf("User '%s': %s", u.Username, err)
+		}
+		opts.Users = append(opts.Users, user)
+	}
+	for _, t := range ac.Tokens {
+		token := auth.Token{Name: t.Name, Token: t.Token}
+		if token.Scope, err = parseScope(t.Scope); err != nil {
+			return opts, fmt.Errorf("Token '%s': %s", t.Name, err)
+		}
+		opts.Tokens = append(opts.Tokens, token)
+	}
+	if ac.Proxy != nil {
+		opts.Proxy = &auth.ProxyAuth{Header: ac.Proxy.Header}
+		if opts.Proxy.Scope, err = parseScope(ac.Proxy.Scope); err != nil {
+			return opts, fmt.Errorf("Proxy auth: %s", err)
+		}
+		if opts.Proxy.TrustedProxies, err = parseNetworks(ac.Proxy.TrustedProxies); err != nil {
+			return opts, fmt.Errorf("Proxy auth: invalid trusted proxy: %s", err)
+		}
+	}
+	if ac.Anonymous != "" {
+		scope, err := auth.ParseScope(ac.Anonymous)
+		if err != nil {
+			return opts, fmt.Errorf("Anonymous access: %s", err)
+		}
+		opts.Anonymous = &scope
+	}
+	return
+}
+
+type checkConfig struct {
+	Name          string
+	Interval      duration
+	Timeout       duration
+	Cmd           checkCmd
+	Type          string
+	MetricUnit    string        `yaml:"unit"`
+	MaxRetries    *int          `yaml:"maxRetries"`
+	RetryInterval time.Duration `yaml:"retryInterval"`
+	Labels        map[string]string
+	Maintenance   []maintenanceConfig
+	Visibility    string
+	HideOutput    *bool `yaml:"hideOutput"`
+
+	// Checks are shown in ascending order, and in the order they are
+	// written when the order is the same
+	Order int
+
+	// Checks can be based on a template, and expanded into a check for
+	// each of a set of parameters
+	Template string
+	Params   map[string]string
+	ForEach  forEachValues `yaml:"for_each"`
+	Matrix   map[string][]string
+}
+
+type serviceConfig struct {
+	Defaults checkDefaults
+	Checks   []checkConfig
+	Order    int
 
 	OnFailure   []*singleNotificationConfig `yaml:"on_failure"`
 	OnRecovered []*singleNotificationConfig `yaml:"on_recovered"`
 	OnSuccess   []*singleNotificationConfig `yaml:"on_success"`
+	Escalation  EscalationPolicy
+	Maintenance []maintenanceConfig
+	Visibility  string
+	HideOutput  bool `yaml:"hideOutput"`
+}
+
+type configRaw struct {
+	Name           string
+	Port           int
+	Listen         string
+	BasePath       string             `yaml:"basePath"`
+	Proxies        []string           `yaml:"trustedProxies"`
+	HTTPS          PatrolHttpsOptions `yaml:"https"`
+	DB             string             `yaml:"db"`
+	LogLevel       string             `yaml:"logLevel"`
+	APIToken       string             `yaml:"apiToken"`
+	Auth           authConfig         `yaml:"auth"`
+	Uptime         []string           `yaml:"uptimeWindows"`
+	Branding       Branding           `yaml:"branding"`
+	Templates      string             `yaml:"templates"`
+	Pages          []Page             `yaml:"pages"`
+	Compact        history.CompactOptions
+	Include        []string `yaml:"include"`
+	Defaults       checkDefaults
+	CheckTemplates map[string]checkTemplate `yaml:"checkTemplates"`
+	Services       map[string]serviceConfig
+
+	OnFailure   []*singleNotificationConfig `yaml:"on_failure"`
+	OnRecovered []*singleNotificationConfig `yaml:"on_recovered"`
+	OnSuccess   []*singleNotificationConfig `yaml:"on_success"`
+	Escalation  EscalationPolicy
+
+	// Values inserted by interpolation, which are masked when logged
+	secrets []string
+
+	// Names of the services in the order that they are written, across
+	// the included files
+	serviceOrder []string
+}
+
+// Returns the names of the services in the order that they are shown.
+func (raw *configRaw) serviceNames() []string {
+	names := make([]string, 0, len(raw.Services))
+	written := make(map[string]bool, len(raw.serviceOrder))
+	for _, name := range raw.serviceOrder {
+		if _, ok := raw.Services[name]; ok && !written[name] {
+			names = append(names, name)
+			written[name] = true
+		}
+	}
+	var unknown []string
+	for name := range raw.Services {
+		if !written[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	names = append(names, unknown...)
+
+	sort.SliceStable(names, func(i, j int) bool {
+		return raw.Services[names[i]].Order < raw.Services[names[j]].Order
+	})
+	return names
 }
 
 func FromConfigFile(filePath string, historyOptions *history.NewOptions) (*Patrol, configRaw, error) {
+	return FromConfigFiles(filePath, "", historyOptions)
+}
+
+// FromConfigFiles is like FromConfigFile, but also merges in the services
+// defined by every '.yml' and '.yaml' file in configDir (if given).
+func FromConfigFiles(filePath, configDir string, historyOptions *history.NewOptions) (*Patrol, configRaw, error) {
 	buffer, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		return nil, configRaw{}, err
 	}
-	return FromConfig(buffer, historyOptions)
+	var includes []string
+	if configDir != "" {
+		info, err := os.Stat(configDir)
+		if os.IsNotExist(err) {
+			return nil, configRaw{}, fmt.Errorf("config directory does not exist: %s", configDir)
+		} else if err != nil {
+			return nil, configRaw{}, err
+		} else if !info.IsDir() {
+			return nil, configRaw{}, fmt.Errorf("config directory is not a directory: %s", configDir)
+		}
+		includes = []string{filepath.Join(configDir, "*.yml"), filepath.Join(configDir, "*.yaml")}
+	}
+	raw, err := parseConfig(filePath, buffer, includes)
+	if err != nil {
+		return nil, raw, err
+	}
+	return fromConfigRaw(raw, historyOptions)
 }
 
-func FromConfig(data []byte, historyOptions *history.NewOptions) (patrol *Patrol, raw configRaw, err error) {
-	err = yaml.UnmarshalStrict(data, &raw)
+func FromConfig(data []byte, historyOptions *history.NewOptions) (*Patrol, configRaw, error) {
+	raw, err := parseConfig("", data, nil)
 	if err != nil {
-		return
+		return nil, raw, err
 	}
+	return fromConfigRaw(raw, historyOptions)
+}
 
+func fromConfigRaw(parsed configRaw, historyOptions *history.NewOptions) (patrol *Patrol, raw configRaw, err error) {
+	raw = parsed
+	if err = raw.expandChecks(); err != nil {
+		return
+	}
 	if raw.Name == "" {
 		raw.Name = "Statuspage"
 	}
@@ -94,17 +330,46 @@ func FromConfig(data []byte, historyOptions *history.NewOptions) (patrol *Patrol
 	if err != nil {
 		return
 	}
+	if err = raw.Escalation.validate(); err != nil {
+		return
+	}
+	authOptions, err := raw.Auth.options()
+	if err != nil {
+		return
+	}
+	trustedProxies, err := parseNetworks(raw.Proxies)
+	if err != nil {
+		err = fmt.Errorf("Invalid trusted proxy: %s", err)
+		return
+	}
+	uptimeWindows := make([]time.Duration, len(raw.Uptime))
+	for idx, str := range raw.Uptime {
+		if uptimeWindows[idx], err = uptime.ParseWindow(str); err != nil {
+			return
+		}
+	}
 
 	patrolOpts := CreatePatrolOptions{
 		Name:               raw.Name,
 		Port:               uint32(raw.Port),
+		Listen:             raw.Listen,
+		BasePath:           raw.BasePath,
+		TrustedProxies:     trustedProxies,
 		LogLevel:           logLevel,
+		APIToken:           raw.APIToken,
+		Auth:               authOptions,
+		UptimeWindows:      uptimeWindows,
+		Branding:           raw.Branding,
+		TemplateDir:        raw.Templates,
+		Pages:              raw.Pages,
 		GroupEventHandlers: make(map[string]EventHandlers),
 		GlobalEventHandlers: EventHandlers{
 			"healthy":   raw.OnSuccess,
 			"recovered": raw.OnRecovered,
 			"unhealthy": raw.OnFailure,
 		},
+		GroupEscalationPolicies: make(map[string]EscalationPolicy),
+		GlobalEscalationPolicy:  raw.Escalation,
 	}
 
 	if historyOptions == nil {
@@ -132,11 +397,21 @@ func FromConfig(data []byte, historyOptions *history.NewOptions) (patrol *Patrol
 		err = fmt.Errorf("Config file contains no services")
 		return
 	}
-	for group, groupConfig := range raw.Services {
+	for _, group := range raw.serviceNames() {
+		groupConfig := raw.Services[group]
 		if groupConfig.Checks == nil || len(groupConfig.Checks) == 0 {
 			err = fmt.Errorf("Empty group '%s' defined in config", group)
 			return
 		}
+		if err = validateVisibility(groupConfig.Visibility); err != nil {
+			err = fmt.Errorf("Invalid visibility of %s: %s", group, err)
+			return
+		}
+		var groupMaintenance maintenance.Windows
+		if groupMaintenance, err = parseMaintenanceWindows(groupConfig.Maintenance); err != nil {
+			err = fmt.Errorf("Invalid maintenance in %s: %s", group, err)
+			return
+		}
 
 		for idx, checkConfig := range groupConfig.Checks {
 			if checkConfig.Type == "" {
@@ -169,6 +444,23 @@ func FromConfig(data []byte, historyOptions *history.NewOptions) (patrol *Patrol
 				checkConfig.RetryInterval = 1 * time.Minute
 			}
 
+			var checkMaintenance maintenance.Windows
+			if checkMaintenance, err = parseMaintenanceWindows(checkConfig.Maintenance); err != nil {
+				err = fmt.Errorf("Invalid maintenance for %d-th check in %s: %s", idx, group, err)
+				return
+			}
+
+			if checkConfig.Visibility == "" {
+				checkConfig.Visibility = groupConfig.Visibility
+			} else if err = validateVisibility(checkConfig.Visibility); err != nil {
+				err = fmt.Errorf("Invalid visibility of %d-th check in %s: %s", idx, group, err)
+				return
+			}
+			hideOutput := groupConfig.HideOutput
+			if checkConfig.HideOutput != nil {
+				hideOutput = *checkConfig.HideOutput
+			}
+
 			groupConfig.Checks[idx] = checkConfig
 			patrolOpts.Checkers = append(patrolOpts.Checkers, checker.New(&checker.Checker{
 				Group:         group,
@@ -178,9 +470,13 @@ func FromConfig(data []byte, historyOptions *history.NewOptions) (patrol *Patrol
 				MetricUnit:    checkConfig.MetricUnit,
 				MaxRetries:    maxRetries,
 				RetryInterval: checkConfig.RetryInterval,
+				Labels:        checkConfig.Labels,
+				Maintenance:   append(checkMaintenance, groupMaintenance...),
 				Interval:      checkConfig.Interval.duration(),
 				CmdTimeout:    checkConfig.Timeout.duration(),
 				History:       historyFile,
+				Visibility:    checkConfig.Visibility,
+				HideOutput:    hideOutput,
 			}))
 		}
 
@@ -189,6 +485,11 @@ func FromConfig(data []byte, historyOptions *history.NewOptions) (patrol *Patrol
 			"recovered": groupConfig.OnRecovered,
 			"unhealthy": groupConfig.OnFailure,
 		}
+		if err = groupConfig.Escalation.validate(); err != nil {
+			err = fmt.Errorf("Invalid escalation policy in %s: %s", group, err)
+			return
+		}
+		patrolOpts.GroupEscalationPolicies[group] = groupConfig.Escalation
 	}
 
 	patrol, err = New(patrolOpts, historyFile)
@@ -0,0 +1,183 @@
+package patrol
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Summary of the config that is safe to log: it leaves out the commands of
+// checks and credentials, and redacts the sensitive parts of notifiers.
+type configSummary struct {
+	Name      string            `json:"name"`
+	Listen    string            `json:"listen,omitempty"`
+	Port      int               `json:"port"`
+	HTTPSPort uint32            `json:"httpsPort,omitempty"`
+	BasePath  string            `json:"basePath,omitempty"`
+	DB        string            `json:"db"`
+	LogLevel  string            `json:"logLevel"`
+	Auth      authSummary       `json:"auth"`
+	Pages     []string          `json:"pages,omitempty"`
+	Services  []serviceSummary  `json:"services"`
+	Notifiers []notifierSummary `json:"notifiers,omitempty"`
+}
+
+type authSummary struct {
+	APIToken    bool     `json:"apiToken"`
+	Users       []string `json:"users,omitempty"`
+	Tokens      []string `json:"tokens,omitempty"`
+	ProxyHeader string   `json:"proxyHeader,omitempty"`
+	Anonymous   string   `json:"anonymous,omitempty"`
+}
+
+type serviceSummary struct {
+	Name       string            `json:"name"`
+	Visibility string            `json:"visibility,omitempty"`
+	Checks     []checkSummary    `json:"checks"`
+	Notifiers  []notifierSummary `json:"notifiers,omitempty"`
+}
+
+type checkSummary struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Interval   string `json:"interval"`
+	Timeout    string `json:"timeout"`
+	Visibility string `json:"visibility,omitempty"`
+}
+
+type notifierSummary struct {
+	On      string            `json:"on"`
+	Type    string            `json:"type"`
+	Method  string            `json:"method,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	Command string            `json:"command,omitempty"`
+}
+
+// Returns only the scheme and host of the URL, since the rest of it (such
+// as the path of a Slack webhook) often holds credentials.
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	redacted := (&url.URL{Scheme: u.Scheme, Host: u.Host}).String()
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		redacted += "/" + maskedValue
+	}
+	return redacted
+}
+
+func redactString(str string) string {
+	if str == "" {
+		return ""
+	}
+	return maskedValue
+}
+
+func summarizeNotifiers(on string, notifiers []*singleNotificationConfig) []notifierSummary {
+	var summaries []notifierSummary
+	for _, n := range notifiers {
+		switch {
+		case n.Webhook != nil:
+			summary := notifierSummary{
+				On:     on,
+				Type:   "webhook",
+				Method: n.Webhook.Method,
+				URL:    redactURL(n.Webhook.URL),
+				Body:   redactString(n.Webhook.Body),
+			}
+			if len(n.Webhook.Headers) > 0 {
+				summary.Headers = make(map[string]string, len(n.Webhook.Headers))
+				for key, value := range n.Webhook.Headers {
+					summary.Headers[key] = redactString(value)
+				}
+			}
+			summaries = append(summaries, summary)
+		case n.Command != nil:
+			summaries = append(summaries, notifierSummary{
+				On:      on,
+				Type:    "command",
+				Command: redactString(n.Command.command),
+			})
+		}
+	}
+	return summaries
+}
+
+func summarizeHandlers(onFailure, onRecovered, onSuccess []*singleNotificationConfig, escalation EscalationPolicy) []notifierSummary {
+	summaries := summarizeNotifiers("failure", onFailure)
+	summaries = append(summaries, summarizeNotifiers("recovered", onRecovered)...)
+	summaries = append(summaries, summarizeNotifiers("success", onSuccess)...)
+	for _, step := range escalation {
+		if step != nil {
+			summaries = append(summaries, summarizeNotifiers(fmt.Sprintf("unhealthy for %s", step.After.duration()), step.Notify)...)
+		}
+	}
+	return summaries
+}
+
+func (raw configRaw) summary() configSummary {
+	summary := configSummary{
+		Name:      raw.Name,
+		Listen:    raw.Listen,
+		Port:      raw.Port,
+		HTTPSPort: raw.HTTPS.Port,
+		BasePath:  raw.BasePath,
+		DB:        raw.DB,
+		LogLevel:  raw.LogLevel,
+		Auth: authSummary{
+			APIToken:  raw.APIToken != "",
+			Anonymous: raw.Auth.Anonymous,
+		},
+		Notifiers: summarizeHandlers(raw.OnFailure, raw.OnRecovered, raw.OnSuccess, raw.Escalation),
+	}
+	for _, user := range raw.Auth.Users {
+		summary.Auth.Users = append(summary.Auth.Users, user.Username)
+	}
+	for _, token := range raw.Auth.Tokens {
+		summary.Auth.Tokens = append(summary.Auth.Tokens, token.Name)
+	}
+	if raw.Auth.Proxy != nil {
+		summary.Auth.ProxyHeader = raw.Auth.Proxy.Header
+	}
+	for _, page := range raw.Pages {
+		summary.Pages = append(summary.Pages, page.Hostname+page.Path)
+	}
+
+	for _, group := range raw.serviceNames() {
+		groupConfig := raw.Services[group]
+		service := serviceSummary{
+			Name:       group,
+			Visibility: groupConfig.Visibility,
+			Checks:     make([]checkSummary, 0, len(groupConfig.Checks)),
+			Notifiers:  summarizeHandlers(groupConfig.OnFailure, groupConfig.OnRecovered, groupConfig.OnSuccess, groupConfig.Escalation),
+		}
+		for _, checkConfig := range groupConfig.Checks {
+			service.Checks = append(service.Checks, checkSummary{
+				Name:       checkConfig.Name,
+				Type:       checkConfig.Type,
+				Interval:   checkConfig.Interval.duration().String(),
+				Timeout:    checkConfig.Timeout.duration().String(),
+				Visibility: checkConfig.Visibility,
+			})
+		}
+		summary.Services = append(summary.Services, service)
+	}
+	return summary
+}
+
+// LogJSON returns the config as it should be logged. Unless showSecrets is
+// set, this is a summary that leaves out sensitive values, with the values
+// of environment variables and secret files that were interpolated into the
+// config masked. Otherwise, it is the entire config.
+func (raw configRaw) LogJSON(showSecrets bool) ([]byte, error) {
+	if showSecrets {
+		return json.MarshalIndent(raw, "", "\t")
+	}
+	data, err := json.MarshalIndent(raw.summary(), "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return maskJSON(data, raw.secrets)
+}
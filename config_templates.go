@@ -0,0 +1,303 @@
+package patrol
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+)
+
+var paramPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// Defaults for the checks of every service, or of a single service. The
+// notifiers are used by services that do not have their own.
+type checkDefaults struct {
+	Interval      duration
+	Timeout       duration
+	MaxRetries    *int          `yaml:"maxRetries"`
+	RetryInterval time.Duration `yaml:"retryInterval"`
+
+	OnFailure   []*singleNotificationConfig `yaml:"on_failure"`
+	OnRecovered []*singleNotificationConfig `yaml:"on_recovered"`
+	OnSuccess   []*singleNotificationConfig `yaml:"on_success"`
+}
+
+// Fills in the options that the check does not set.
+func (d checkDefaults) apply(c checkConfig) checkConfig {
+	if c.Interval.isZero() {
+		c.Interval = d.Interval
+	}
+	if c.Timeout.isZero() {
+		c.Timeout = d.Timeout
+	}
+	if c.MaxRetries == nil {
+		c.MaxRetries = d.MaxRetries
+	}
+	if c.RetryInterval == 0 {
+		c.RetryInterval = d.RetryInterval
+	}
+	return c
+}
+
+// Named check that other checks can be based on. Its parameters are
+// referred to as '{{name}}' in the name, command, unit, and labels of the
+// check.
+type checkTemplate struct {
+	Params []string
+	Check  checkConfig
+}
+
+// Values that a check is expanded for: either a list of values, which are
+// available as the 'item' parameter (and as the parameter of templates with
+// only one), or a list of sets of parameters.
+type forEachValues []map[string]string
+
+func (values *forEachValues) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var params []map[string]string
+	if err := unmarshal(&params); err == nil {
+		*values = params
+		return nil
+	}
+	var items []string
+	if err := unmarshal(&items); err != nil {
+		return err
+	}
+	*values = make(forEachValues, len(items))
+	for idx, item := range items {
+		(*values)[idx] = map[string]string{"item": item}
+	}
+	return nil
+}
+
+// Fills in the options that the check does not set from the given check.
+func (c checkConfig) inherit(base checkConfig) checkConfig {
+	if c.Name == "" {
+		c.Name = base.Name
+	}
+	if c.Interval.isZero() {
+		c.Interval = base.Interval
+	}
+	if c.Timeout.isZero() {
+		c.Timeout = base.Timeout
+	}
+	if c.Cmd.isZero() {
+		c.Cmd = base.Cmd
+	}
+	if c.Type == "" {
+		c.Type = base.Type
+	}
+	if c.MetricUnit == "" {
+		c.MetricUnit = base.MetricUnit
+	}
+	if c.MaxRetries == nil {
+		c.MaxRetries = base.MaxRetries
+	}
+	if c.RetryInterval == 0 {
+		c.RetryInterval = base.RetryInterval
+	}
+	if len(c.Maintenance) == 0 {
+		c.Maintenance = base.Maintenance
+	}
+	if c.Visibility == "" {
+		c.Visibility = base.Visibility
+	}
+	if c.HideOutput == nil {
+		c.HideOutput = base.HideOutput
+	}
+	if len(base.Labels) > 0 {
+		labels := make(map[string]string, len(base.Labels)+len(c.Labels))
+		for key, value := range base.Labels {
+			labels[key] = value
+		}
+		for key, value := range c.Labels {
+			labels[key] = value
+		}
+		c.Labels = labels
+	}
+	return c
+}
+
+func substituteParams(str string, params map[string]string) (string, error) {
+	var err error
+	str = paramPattern.ReplaceAllStringFunc(str, func(match string) string {
+		name := paramPattern.FindStringSubmatch(match)[1]
+		value, ok := params[name]
+		if !ok && err == nil {
+			err = fmt.Errorf("Unknown parameter '%s'", name)
+		}
+		return value
+	})
+	return str, err
+}
+
+// Replaces references to the parameters in the check.
+func (c checkConfig) withParams(params map[string]string) (checkConfig, error) {
+	var err error
+	if c.Name, err = substituteParams(c.Name, params); err != nil {
+		return c, err
+	}
+	cmd, err := substituteParams(c.Cmd.String(), params)
+	if err != nil {
+		return c, err
+	}
+	c.Cmd = checkCmd(cmd)
+	if c.MetricUnit, err = substituteParams(c.MetricUnit, params); err != nil {
+		return c, err
+	}
+	if len(c.Labels) > 0 {
+		labels := make(map[string]string, len(c.Labels))
+		for key, value := range c.Labels {
+			if labels[key], err = substituteParams(value, params); err != nil {
+				return c, err
+			}
+		}
+		c.Labels = labels
+	}
+	return c, nil
+}
+
+// Returns the sets of parameters that the check should be expanded for.
+func (c checkConfig) paramSets() ([]map[string]string, error) {
+	if len(c.ForEach) > 0 && len(c.Matrix) > 0 {
+		return nil, fmt.Errorf("Cannot use both for_each and matrix")
+	}
+
+	sets := []map[string]string{{}}
+	if len(c.ForEach) > 0 {
+		sets = c.ForEach
+	}
+	if len(c.Matrix) > 0 {
+		names := make([]string, 0, len(c.Matrix))
+		for name := range c.Matrix {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			var product []map[string]string
+			for _, set := range sets {
+				for _, value := range c.Matrix[name] {
+					params := map[string]string{name: value}
+					for key, value := range set {
+						params[key] = value
+					}
+					product = append(product, params)
+				}
+			}
+			sets = product
+		}
+	}
+
+	// Parameters given to every check are overridden by expanded ones
+	merged := make([]map[string]string, len(sets))
+	for idx, set := range sets {
+		merged[idx] = make(map[string]string, len(c.Params)+len(set))
+		for key, value := range c.Params {
+			merged[idx][key] = value
+		}
+		for key, value := range set {
+			merged[idx][key] = value
+		}
+	}
+	return merged, nil
+}
+
+// Returns the checks that the given check expands into.
+func (raw *configRaw) expandCheck(c checkConfig) ([]checkConfig, error) {
+	if c.Template == "" && c.Params == nil && c.ForEach == nil && c.Matrix == nil {
+		return []checkConfig{c}, nil
+	}
+
+	var template *checkTemplate
+	if c.Template != "" {
+		t, ok := raw.CheckTemplates[c.Template]
+		if !ok {
+			return nil, fmt.Errorf("Unknown template '%s'", c.Template)
+		}
+		if t.Check.Template != "" || t.Check.Params != nil || t.Check.ForEach != nil || t.Check.Matrix != nil {
+			return nil, fmt.Errorf("Template '%s' cannot use template, params, for_each, or matrix", c.Template)
+		}
+		template = &t
+	}
+
+	sets, err := c.paramSets()
+	if err != nil {
+		return nil, err
+	}
+	checks := make([]checkConfig, 0, len(sets))
+	for _, params := range sets {
+		check := c
+		check.Template, check.Params, check.ForEach, check.Matrix = "", nil, nil, nil
+		if template != nil {
+			// Plain values of for_each are given to templates with a single parameter
+			if item, ok := params["item"]; ok && len(template.Params) == 1 {
+				if _, ok := params[template.Params[0]]; !ok {
+					params[template.Params[0]] = item
+				}
+			}
+			declared := make(map[string]bool, len(template.Params))
+			for _, name := range template.Params {
+				declared[name] = true
+				if _, ok := params[name]; !ok {
+					return nil, fmt.Errorf("Missing parameter '%s' of template '%s'", name, c.Template)
+				}
+			}
+			for name := range params {
+				if !declared[name] && name != "item" {
+					return nil, fmt.Errorf("Template '%s' has no parameter '%s'", c.Template, name)
+				}
+			}
+			check = check.inherit(template.Check)
+		}
+		if check, err = check.withParams(params); err != nil {
+			return nil, err
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// Expands templates and matrices into the checks that they describe, and
+// fills in defaults, so that the config can be validated as if every check
+// had been written out.
+func (raw *configRaw) expandChecks() error {
+	for group, service := range raw.Services {
+		checks := make([]checkConfig, 0, len(service.Checks))
+		names := make(map[string]bool, len(service.Checks))
+		for idx, c := range service.Checks {
+			expanded, err := raw.expandCheck(c)
+			if err != nil {
+				return fmt.Errorf("Invalid %d-th check in %s: %s", idx, group, err)
+			}
+			for _, check := range expanded {
+				check = raw.Defaults.apply(service.Defaults.apply(check))
+				if check.Name != "" && names[check.Name] {
+					return fmt.Errorf("Check '%s' is defined more than once in %s", check.Name, group)
+				}
+				names[check.Name] = true
+				checks = append(checks, check)
+			}
+		}
+		sort.SliceStable(checks, func(i, j int) bool {
+			return checks[i].Order < checks[j].Order
+		})
+		service.Checks = checks
+
+		for _, notifiers := range []struct {
+			handlers                      *[]*singleNotificationConfig
+			serviceDefault, globalDefault []*singleNotificationConfig
+		}{
+			{&service.OnFailure, service.Defaults.OnFailure, raw.Defaults.OnFailure},
+			{&service.OnRecovered, service.Defaults.OnRecovered, raw.Defaults.OnRecovered},
+			{&service.OnSuccess, service.Defaults.OnSuccess, raw.Defaults.OnSuccess},
+		} {
+			if *notifiers.handlers == nil {
+				*notifiers.handlers = notifiers.serviceDefault
+			}
+			if *notifiers.handlers == nil {
+				*notifiers.handlers = notifiers.globalDefault
+			}
+		}
+		raw.Services[group] = service
+	}
+	return nil
+}
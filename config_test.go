@@ -1,14 +1,21 @@
 package patrol
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 const configStr = `
 db: config-test.db
 name: MyApp Status
 port: 80
+listen: 127.0.0.1
+basePath: /status/
+trustedProxies: [10.0.0.0/8, '::1']
 services:
   API:
     checks:
@@ -36,15 +43,52 @@ services:
       interval: 60s
       cmd: 'curl -fsSL -w "%{time_total}" -o /dev/null https://google.ca'
   Redis:
+    visibility: internal
+    hideOutput: true
     checks:
     - name: Responds to pings
       interval: 60s
       cmd: '! redis-cli -h redis.ca -n 0 -a pass ping | grep ERR'
+      visibility: public
   Mongo:
     checks:
     - name: Users exist
       interval: 60s
       cmd: 'echo doing stuff'
+      maintenance:
+      - start: 2021-01-01T02:00:00Z
+        end: 2021-01-01T04:00:00Z
+    maintenance:
+    - cron: '0 2 * * SUN'
+      duration: 2h
+pages:
+- name: MyApp Web
+  hostname: status.myapp.ca
+  services: [Web]
+  visibility: public
+- name: MyApp Internal
+  path: /internal
+  visibility: internal
+branding:
+  logo: https://myapp.com/logo.png
+  favicon: /favicon.ico
+  headerColor: '#1a202c'
+  accentColor: '#2b6cb0'
+  footerLinks:
+  - title: Contact us
+    url: https://myapp.com/contact
+  css: 'header { border-bottom: 4px solid #2b6cb0; }'
+auth:
+  users:
+  - username: admin
+    passwordHash: '$2a$04$ewYj/ByFVqu3wmUUzFA2QOW9TP6EFMH3XPFiJ.e88Uso1ghWTn4c.'
+    scope: admin
+  tokens:
+  - name: ci
+    token: secret-token
+  proxy:
+    header: X-Forwarded-User
+    trustedProxies: [10.0.0.0/8, 127.0.0.1]
 on_failure:
 - command: echo hello world
 on_success:
@@ -57,9 +101,242 @@ on_success:
 `
 
 func TestConfigValidate(t *testing.T) {
-	os.Remove("config-test.db")
+	removeTestDB("config-test.db")
+	defer removeTestDB("config-test.db")
 	if _, _, err := FromConfig([]byte(configStr), nil); err != nil {
 		t.Error(err)
 		return
 	}
 }
+
+func TestConfigSummary(t *testing.T) {
+	removeTestDB("config-test.db")
+	defer removeTestDB("config-test.db")
+	p, raw, err := FromConfig([]byte(configStr), nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer p.Close()
+
+	summary, err := raw.LogJSON(false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, secret := range []string{
+		"heroku-token",
+		"secret-token",
+		"$2a$04$",
+		"redis-cli",
+		"MY_CUSTOM_WEBHOOK",
+		"MY_HEROKU_APP",
+		"echo hello world",
+		"completed",
+	} {
+		if strings.Contains(string(summary), secret) {
+			t.Error(fmt.Errorf("Expected config summary to not contain '%s', got: %s", secret, summary))
+		}
+	}
+	for _, expected := range []string{
+		`"name": "Responds to pings"`,
+		`"url": "https://api.heroku.com/******"`,
+		`"Authorization": "******"`,
+		`"users": [`,
+		`"tokens": [`,
+		`"on": "success"`,
+	} {
+		if !strings.Contains(string(summary), expected) {
+			t.Error(fmt.Errorf("Expected config summary to contain '%s', got: %s", expected, summary))
+		}
+	}
+
+	full, err := raw.LogJSON(true)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !strings.Contains(string(full), "heroku-token") {
+		t.Error(fmt.Errorf("Expected secrets to be shown when asked for"))
+	}
+}
+
+func TestConfigInterpolation(t *testing.T) {
+	removeTestDB("config-test.db")
+	defer removeTestDB("config-test.db")
+	secretFile, err := ioutil.TempFile("", "patrol-secret")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.Remove(secretFile.Name())
+	secretFile.WriteString("heroku-secret-'token\n")
+	secretFile.Close()
+
+	os.Setenv("PATROL_TEST_NAME", `*Interpolated #1: "Status"`)
+	os.Setenv("PATROL_TEST_HOOK", "T0KEN/W3BH00K")
+	os.Unsetenv("PATROL_TEST_PORT")
+	os.Unsetenv("PATROL_TEST_MISSING")
+	os.Unsetenv("PATROL_TEST_CHECK")
+	config := `
+db: config-test.db
+# Comments are not interpolated: ${PATROL_TEST_MISSING}
+name: ${PATROL_TEST_NAME}
+port: ${PATROL_TEST_PORT:-8081}
+services:
+  API:
+    checks:
+      - name: ${PATROL_TEST_CHECK:-API} Status
+        interval: 60s
+        cmd: 'test -n "$${HOME}"'
+    on_failure:
+    - webhook:
+        method: post
+        url: https://hooks.slack.com/services/${PATROL_TEST_HOOK}
+        headers:
+          Authorization: 'Bearer ${file:` + secretFile.Name() + `}'
+`
+	p, raw, err := FromConfig([]byte(config), nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer p.Close()
+
+	if raw.Name != `*Interpolated #1: "Status"` || raw.Port != 8081 {
+		t.Error(fmt.Errorf("Expected environment variables to be interpolated, got: %s %d", raw.Name, raw.Port))
+	}
+	if cmd := raw.Services["API"].Checks[0].Cmd.String(); cmd != `test -n "${HOME}"` {
+		t.Error(fmt.Errorf("Expected '$${' to be kept as '${', got: %s", cmd))
+	}
+	webhook := raw.Services["API"].OnFailure[0].Webhook
+	if webhook.URL.Path != "/services/T0KEN/W3BH00K" || webhook.Headers["Authorization"] != "Bearer heroku-secret-'token" {
+		t.Error(fmt.Errorf("Expected webhook to use interpolated secrets, got: %s %s", webhook.URL, webhook.Headers))
+	}
+
+	dump, err := raw.LogJSON(false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if strings.Contains(string(dump), "heroku-secret") || strings.Contains(string(dump), "Interpolated") || strings.Contains(string(dump), "T0KEN") || !strings.Contains(string(dump), `"url": "https://hooks.slack.com/******"`) || !strings.Contains(string(dump), `"name": "API Status"`) {
+		t.Error(fmt.Errorf("Expected secrets to be masked in config dump, got: %s", dump))
+	}
+
+	for _, invalid := range []string{
+		"db: config-test.db\nname: ${PATROL_TEST_MISSING}\n",
+		"db: config-test.db\nname: ${file:/nonexistent/secret}\n",
+		"db: config-test.db\nname: ${not a var}\n",
+	} {
+		if _, _, err := FromConfig([]byte(invalid), nil); err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Error(fmt.Errorf("Expected invalid reference to be rejected with its line, got: %v", err))
+		}
+	}
+}
+
+func TestConfigTemplates(t *testing.T) {
+	removeTestDB("config-test.db")
+	defer removeTestDB("config-test.db")
+	config := `
+db: config-test.db
+defaults:
+  interval: 5m
+  maxRetries: 1
+  on_failure:
+  - command: echo default
+checkTemplates:
+  http:
+    params: [host]
+    check:
+      name: '{{host}} responds'
+      cmd: 'curl -fsSL https://{{ host }}/'
+      timeout: 30s
+      labels:
+        host: '{{host}}'
+services:
+  Web:
+    defaults:
+      interval: 1m
+    checks:
+    - template: http
+      for_each: [a.example.com, b.example.com]
+      maxRetries: 5
+    - template: http
+      params: {host: c.example.com}
+      name: Homepage
+    - name: 'Container {{item}} runs'
+      for_each: [web, worker]
+      cmd: docker inspect -f '{{.State.Running}}' {{item}}
+    on_failure:
+    - command: echo web
+  Regions:
+    checks:
+    - name: '{{service}} in {{region}}'
+      matrix:
+        service: [api, cdn]
+        region: [us, eu]
+      cmd: 'true'
+`
+	p, raw, err := FromConfig([]byte(config), nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer p.Close()
+
+	type expectedCheck struct {
+		name, cmd, host string
+		interval        time.Duration
+		timeout         time.Duration
+		maxRetries      int
+	}
+	for group, expected := range map[string][]expectedCheck{
+		"Web": {
+			{"a.example.com responds", "curl -fsSL https://a.example.com/", "a.example.com", time.Minute, 30 * time.Second, 5},
+			{"b.example.com responds", "curl -fsSL https://b.example.com/", "b.example.com", time.Minute, 30 * time.Second, 5},
+			{"Homepage", "curl -fsSL https://c.example.com/", "c.example.com", time.Minute, 30 * time.Second, 1},
+			{"Container web runs", "docker inspect -f '{{.State.Running}}' web", "", time.Minute, 3 * time.Minute, 1},
+			{"Container worker runs", "docker inspect -f '{{.State.Running}}' worker", "", time.Minute, 3 * time.Minute, 1},
+		},
+		"Regions": {
+			{"api in us", "true", "", 5 * time.Minute, 3 * time.Minute, 1},
+			{"api in eu", "true", "", 5 * time.Minute, 3 * time.Minute, 1},
+			{"cdn in us", "true", "", 5 * time.Minute, 3 * time.Minute, 1},
+			{"cdn in eu", "true", "", 5 * time.Minute, 3 * time.Minute, 1},
+		},
+	} {
+		for _, e := range expected {
+			c := p.getChecker(group, e.name)
+			if c == nil {
+				t.Error(fmt.Errorf("Expected check '%s' in %s, got: %v", e.name, group, raw.Services[group].Checks))
+				continue
+			}
+			if c.Cmd != e.cmd || c.Labels["host"] != e.host || c.Interval != e.interval || c.CmdTimeout != e.timeout || c.MaxRetries != e.maxRetries {
+				t.Error(fmt.Errorf("Expected check '%s' to be %#v, got: %#v", e.name, e, c))
+			}
+		}
+		if len(raw.Services[group].Checks) != len(expected) {
+			t.Error(fmt.Errorf("Expected %d checks in %s, got: %d", len(expected), group, len(raw.Services[group].Checks)))
+		}
+	}
+	if onFailure := raw.Services["Web"].OnFailure; len(onFailure) != 1 || onFailure[0].Command.command != "echo web" {
+		t.Error(fmt.Errorf("Expected service notifiers to override defaults"))
+	}
+	if onFailure := raw.Services["Regions"].OnFailure; len(onFailure) != 1 || onFailure[0].Command.command != "echo default" {
+		t.Error(fmt.Errorf("Expected default notifiers to be used by services without their own"))
+	}
+
+	for invalid, expected := range map[string]string{
+		"- template: missing":                                                "Unknown template 'missing'",
+		"- template: http":                                                   "Missing parameter 'host' of template 'http'",
+		"- {template: http, params: {host: a, x: b}}":                        "Template 'http' has no parameter 'x'",
+		"- {name: 'A {{x}}', cmd: 'true', params: {}}":                       "Unknown parameter 'x'",
+		"- {name: A, cmd: 'true', for_each: [a, b]}":                         "Check 'A' is defined more than once in Web",
+		"- {name: '{{item}}', cmd: 'true', for_each: [a], matrix: {b: [c]}}": "Cannot use both for_each and matrix",
+	} {
+		config := "db: config-test.db\ncheckTemplates:\n  http:\n    params: [host]\n    check: {name: '{{host}}', cmd: 'true'}\nservices:\n  Web:\n    checks:\n    " + invalid + "\n"
+		if _, _, err := FromConfig([]byte(config), nil); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Error(fmt.Errorf("Expected '%s' to fail with '%s', got: %v", invalid, expected, err))
+		}
+	}
+}
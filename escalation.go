@@ -0,0 +1,126 @@
+package patrol
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A single step of an escalation policy. The notifiers of a step are run
+// once a check has been continuously unhealthy for at least 'After'.
+type escalationStep struct {
+	After  duration
+	Notify []*singleNotificationConfig
+}
+
+// Ordered list of steps that should be run while a check stays unhealthy.
+// Pending steps are cancelled as soon as the check recovers or the failure
+// is acknowledged.
+type EscalationPolicy []*escalationStep
+
+func (policy EscalationPolicy) validate() error {
+	for idx, step := range policy {
+		if step == nil || len(step.Notify) == 0 {
+			return fmt.Errorf("%d-th escalation step has no notifiers", idx)
+		}
+		if step.After < 0 {
+			return fmt.Errorf("%d-th escalation step has a negative delay", idx)
+		}
+	}
+	return nil
+}
+
+// State of the escalation for a single unhealthy check.
+type escalation struct {
+	timers []*time.Timer
+}
+
+func (e *escalation) cancel() {
+	for _, timer := range e.timers {
+		timer.Stop()
+	}
+	e.timers = nil
+}
+
+type escalationManager struct {
+	rwMux  *sync.RWMutex
+	active map[string]*escalation
+}
+
+func newEscalationManager() *escalationManager {
+	return &escalationManager{
+		rwMux:  &sync.RWMutex{},
+		active: make(map[string]*escalation),
+	}
+}
+
+func escalationKey(group, check string) string {
+	return group + "|" + check
+}
+
+func (p *Patrol) escalate(status, group, check string) {
+	key := escalationKey(group, check)
+	p.escalations.rwMux.Lock()
+	defer p.escalations.rwMux.Unlock()
+
+	if status != "unhealthy" {
+		if e, ok := p.escalations.active[key]; ok {
+			p.logger.Debugf("Cancelling escalation for %s", key)
+			e.cancel()
+			delete(p.escalations.active, key)
+		}
+		return
+	}
+	if _, ok := p.escalations.active[key]; ok {
+		return
+	}
+
+	e := &escalation{}
+	for _, policy := range []EscalationPolicy{p.globalEscalation, p.groupEscalations[group]} {
+		for idx, step := range policy {
+			idx, step := idx, step
+			e.timers = append(e.timers, time.AfterFunc(step.After.duration(), func() {
+				if reason := p.suppressedBy("unhealthy", group, check); reason != "" {
+					p.logger.Debugf("Skipping escalation step #%d for %s: %s", idx, key, reason)
+					return
+				}
+				p.logger.Infof("Escalating %s to step #%d (unhealthy for %s)", key, idx, step.After.duration())
+				for _, n := range step.Notify {
+					p.notify(n)
+				}
+			}))
+		}
+	}
+	p.escalations.active[key] = e
+}
+
+// Acknowledge marks the current failure of a check as known. Notifications
+// and pending escalation steps for the check are suppressed until it
+// recovers. It returns false if the check is not currently unhealthy.
+func (p *Patrol) Acknowledge(group, check string) (bool, error) {
+	items := p.History.GetGroupItems(group, check)
+	if len(items) == 0 || items[0].Status != "unhealthy" {
+		return false, nil
+	}
+	if err := p.silences.Acknowledge(group, check); err != nil {
+		return false, err
+	}
+
+	key := escalationKey(group, check)
+	p.escalations.rwMux.Lock()
+	if e, ok := p.escalations.active[key]; ok {
+		e.cancel()
+	}
+	p.escalations.rwMux.Unlock()
+
+	p.logger.Infof("Failure acknowledged for %s", key)
+	return true, nil
+}
+
+func (p *Patrol) stopEscalations() {
+	p.escalations.rwMux.Lock()
+	for _, e := range p.escalations.active {
+		e.cancel()
+	}
+	p.escalations.rwMux.Unlock()
+}
@@ -0,0 +1,115 @@
+package patrol
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karimsa/patrol/internal/history"
+	"github.com/karimsa/patrol/internal/silence"
+)
+
+func commandStep(after time.Duration, command string) *escalationStep {
+	return &escalationStep{
+		After: duration(after),
+		Notify: []*singleNotificationConfig{
+			{Command: &commandNotification{command: command}},
+		},
+	}
+}
+
+func TestEscalation(t *testing.T) {
+	removeTestDB("escalation-test.db")
+	defer removeTestDB("escalation-test.db")
+	historyFile, err := history.New(history.NewOptions{
+		File: "escalation-test.db",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	fd, err := ioutil.TempFile(os.TempDir(), "*")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	fd.Close()
+	defer os.Remove(fd.Name())
+
+	p, err := New(CreatePatrolOptions{
+		GroupEscalationPolicies: map[string]EscalationPolicy{
+			"foo": {
+				commandStep(0, fmt.Sprintf("echo team >> %s", fd.Name())),
+				commandStep(200*time.Millisecond, fmt.Sprintf("echo primary >> %s", fd.Name())),
+				commandStep(time.Hour, fmt.Sprintf("echo secondary >> %s", fd.Name())),
+			},
+		},
+	}, historyFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer historyFile.Close()
+
+	if _, err := historyFile.Append(history.Item{
+		Group:  "foo",
+		Name:   "bar",
+		Type:   "boolean",
+		Status: "unhealthy",
+	}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Repeated failures should not restart the escalation
+	p.OnCheckerStatus("unhealthy", "foo", "bar")
+	p.OnCheckerStatus("unhealthy", "foo", "bar")
+	<-time.After(500 * time.Millisecond)
+	if ok, err := p.Acknowledge("foo", "bar"); err != nil || !ok {
+		t.Error(fmt.Errorf("Failed to acknowledge failure: %v", err))
+		return
+	}
+	p.OnCheckerStatus("unhealthy", "foo", "bar")
+	<-time.After(200 * time.Millisecond)
+
+	data, err := ioutil.ReadFile(fd.Name())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); fmt.Sprintf("%#v", lines) != `[]string{"team", "primary"}` {
+		t.Error(fmt.Errorf("Wrong escalation steps were run: %#v", lines))
+		return
+	}
+
+	p.OnCheckerStatus("recovered", "foo", "bar")
+	if p.silences.IsAcknowledged("foo", "bar") {
+		t.Error(fmt.Errorf("Acknowledgement should be cleared after recovery"))
+		return
+	}
+	if _, ok := p.escalations.active[escalationKey("foo", "bar")]; ok {
+		t.Error(fmt.Errorf("Escalation should be cancelled after recovery"))
+		return
+	}
+
+	// Failures that start during a silence are escalated once it expires
+	if _, err := p.silences.Add(silence.Silence{Group: "foo", EndsAt: time.Now().Add(100 * time.Millisecond)}); err != nil {
+		t.Error(err)
+		return
+	}
+	p.OnCheckerStatus("unhealthy", "foo", "bar")
+	<-time.After(400 * time.Millisecond)
+	data, err = ioutil.ReadFile(fd.Name())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); fmt.Sprintf("%#v", lines) != `[]string{"team", "primary", "primary"}` {
+		t.Error(fmt.Errorf("Expected escalation to continue after the silence expired: %#v", lines))
+	}
+	p.OnCheckerStatus("recovered", "foo", "bar")
+}
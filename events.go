@@ -0,0 +1,149 @@
+package patrol
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/karimsa/patrol/internal/events"
+	"github.com/karimsa/patrol/internal/history"
+)
+
+// Number of recent events kept for clients resuming with 'Last-Event-ID'.
+const eventBufferSize = 1000
+
+// Interval at which comments are sent to keep idle event streams open.
+const eventKeepAlive = 15 * time.Second
+
+type statusEvent struct {
+	Group     string    `json:"group"`
+	Check     string    `json:"check"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func newEventBroker(data map[string]map[string][]history.Item) *events.Broker {
+	broker := events.New(eventBufferSize)
+	for groupName, group := range data {
+		for checkName, items := range group {
+			if len(items) > 0 {
+				broker.Transition(groupName, checkName, items[0].Status)
+			}
+		}
+	}
+	return broker
+}
+
+func (p *Patrol) publishItem(item history.Item) {
+	if err := p.events.Publish("item", item.Group, item.Name, item); err != nil {
+		p.logger.Warnf("Failed to publish item event: %s", err)
+	}
+}
+
+func (p *Patrol) publishStatus(status, group, check string) {
+	prev, changed := p.events.Transition(group, check, status)
+	if !changed {
+		return
+	}
+	err := p.events.Publish("status", group, check, statusEvent{
+		Group:     group,
+		Check:     check,
+		From:      prev,
+		To:        status,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		p.logger.Warnf("Failed to publish status event: %s", err)
+	}
+}
+
+func writeEvent(res http.ResponseWriter, event events.Event) error {
+	_, err := fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+	return err
+}
+
+// Returns the event as it should be seen by the request, or false if the
+// check it belongs to is not visible.
+func (p *Patrol) visibleEvent(req *http.Request, event events.Event) (events.Event, bool) {
+	if !p.canSee(req, event.Group, event.Check) {
+		return event, false
+	}
+	if event.Type == "item" && p.hidesOutput(req, event.Group, event.Check) {
+		var item history.Item
+		if err := json.Unmarshal(event.Data, &item); err != nil {
+			return event, false
+		}
+		data, err := json.Marshal(p.sanitizeItem(req, item))
+		if err != nil {
+			return event, false
+		}
+		event.Data = data
+	}
+	return event, true
+}
+
+func (p *Patrol) serveEvents(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeJSON(res, http.StatusMethodNotAllowed, apiError{"Method not allowed"})
+		return
+	}
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		writeJSON(res, http.StatusInternalServerError, apiError{"Streaming is not supported"})
+		return
+	}
+
+	query := req.URL.Query()
+	lastID := req.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = query.Get("lastEventId")
+	}
+	id, _ := strconv.ParseUint(lastID, 10, 64)
+	sub := p.events.Subscribe(id, query.Get("group"), query.Get("check"))
+	defer p.events.Unsubscribe(sub)
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	for _, event := range sub.Missed {
+		event, ok := p.visibleEvent(req, event)
+		if !ok {
+			continue
+		}
+		if err := writeEvent(res, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+
+		case event, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			if event, ok = p.visibleEvent(req, event); !ok {
+				continue
+			}
+			if err := writeEvent(res, event); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package patrol
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/karimsa/patrol/internal/feed"
+	"github.com/karimsa/patrol/internal/history"
+)
+
+// Maximum number of entries in a feed.
+const maxFeedEntries = 50
+
+// Healthy and recovered results are both considered up when looking for
+// status changes, otherwise each day of a boolean check would start with a
+// change from recovered to healthy.
+func isUp(status string) bool {
+	return status == "healthy" || status == "recovered"
+}
+
+// Returns the items of a check (newest first) at which its status changed,
+// oldest first. The first item is not considered a change.
+func transitions(items []history.Item) []history.Item {
+	var changes []history.Item
+	for idx := len(items) - 2; idx >= 0; idx-- {
+		prev, item := items[idx+1], items[idx]
+		if item.Status != prev.Status && (!isUp(item.Status) || !isUp(prev.Status)) {
+			changes = append(changes, item)
+		}
+	}
+	return changes
+}
+
+func (p *Patrol) buildFeed(req *http.Request, group string) feed.Feed {
+	base := p.baseURL(req)
+	f := feed.Feed{
+		ID:    base + "/",
+		Title: p.pageName(req),
+		Link:  base + "/",
+	}
+	if group != "" {
+		f.ID = base + "/?group=" + url.QueryEscape(group)
+		f.Title = fmt.Sprintf("%s - %s", p.pageName(req), group)
+		f.Link = f.ID
+	}
+
+	for _, g := range p.groupData(p.visibleData(req)) {
+		if group != "" && g.Name != group {
+			continue
+		}
+		for _, check := range g.Checks {
+			for _, item := range transitions(check.Items) {
+				f.Entries = append(f.Entries, feed.Entry{
+					ID:      "urn:patrol:item:" + url.PathEscape(item.ID) + ":" + item.Status,
+					Title:   fmt.Sprintf("%s / %s is %s", g.Name, check.Name, item.Status),
+					Link:    base + "/items/" + url.PathEscape(item.ID),
+					Summary: item.Error,
+					Updated: item.CreatedAt,
+				})
+			}
+		}
+	}
+
+	for _, i := range p.visibleIncidents(req, p.incidents.List(group, "")) {
+		entry := feed.Entry{
+			ID:      "urn:patrol:incident:" + i.ID,
+			Title:   fmt.Sprintf("Incident: %s / %s", i.Group, i.Check),
+			Link:    base + "/incidents/" + i.ID,
+			Updated: i.Start,
+		}
+		summary := []string{fmt.Sprintf("Started at %s.", i.Start.Format(time.RFC1123))}
+		if i.Resolved() {
+			entry.Title += " (resolved)"
+			entry.Updated = i.RecoveredAt
+			summary = append(summary, fmt.Sprintf("Recovered at %s after %s.", i.RecoveredAt.Format(time.RFC1123), i.Duration().Round(time.Second)))
+		}
+		if i.FirstError != "" {
+			summary = append(summary, "Error: "+i.FirstError)
+		}
+		entry.Summary = strings.Join(summary, " ")
+		f.Entries = append(f.Entries, entry)
+	}
+
+	sort.SliceStable(f.Entries, func(i, j int) bool {
+		return f.Entries[i].Updated.After(f.Entries[j].Updated)
+	})
+	if len(f.Entries) > maxFeedEntries {
+		f.Entries = f.Entries[:maxFeedEntries]
+	}
+	if len(f.Entries) > 0 {
+		f.Updated = f.Entries[0].Updated
+	} else {
+		f.Updated = time.Now()
+	}
+	return f
+}
+
+func (p *Patrol) serveFeed(res http.ResponseWriter, req *http.Request) {
+	group := req.URL.Query().Get("group")
+	if group != "" && !p.canSeeGroup(req, group) {
+		res.WriteHeader(http.StatusNotFound)
+		res.Write([]byte("Service not found"))
+		return
+	}
+
+	f := p.buildFeed(req, group)
+	var buffer []byte
+	var err error
+	if req.URL.Path == "/feed.atom" {
+		res.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		buffer, err = f.Atom(p.baseURL(req) + req.URL.RequestURI())
+	} else {
+		res.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		buffer, err = f.RSS()
+	}
+	if err != nil {
+		p.logger.Warnf("Failed to render feed: %s", err)
+		res.WriteHeader(http.StatusInternalServerError)
+		res.Write([]byte(err.Error()))
+		return
+	}
+	res.Write(buffer)
+}
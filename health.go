@@ -0,0 +1,131 @@
+package patrol
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/karimsa/patrol/internal/auth"
+	"github.com/karimsa/patrol/internal/checker"
+)
+
+// Patrol is not ready while more notifications than this are still being
+// sent, since notifications are likely failing to go out.
+const maxNotificationBacklog = 100
+
+type healthCheck struct {
+	Group      string     `json:"group"`
+	Check      string     `json:"check"`
+	Interval   string     `json:"interval"`
+	StaleAfter string     `json:"staleAfter"`
+	LastResult *time.Time `json:"lastResult"`
+	Stale      bool       `json:"stale"`
+}
+
+type healthReport struct {
+	Status               string        `json:"status"`
+	Problems             []string      `json:"problems"`
+	Started              bool          `json:"started"`
+	HistoryWriter        bool          `json:"historyWriter"`
+	PendingWrites        int           `json:"pendingWrites"`
+	CompactionError      string        `json:"compactionError"`
+	PendingNotifications int64         `json:"pendingNotifications"`
+	StaleChecks          int           `json:"staleChecks"`
+	Checks               []healthCheck `json:"checks,omitempty"`
+}
+
+// Returns the longest time that a checker can take to produce a result,
+// including all of its retries.
+func staleAfter(c *checker.Checker) time.Duration {
+	attempts := time.Duration(c.MaxRetries)
+	return c.Interval + attempts*c.CmdTimeout + (attempts-1)*c.RetryInterval
+}
+
+// Reports on the state of patrol itself. Liveness only fails if patrol is
+// stuck (the history writer stopped or checkers stopped producing results),
+// while readiness also fails before the checkers are started, after a failed
+// compaction, and while notifications are piling up.
+func (p *Patrol) health(req *http.Request, readiness bool) healthReport {
+	now := time.Now()
+	started := p.startedAt
+	report := healthReport{
+		Problems:             []string{},
+		Started:              !started.IsZero(),
+		HistoryWriter:        p.History.WriterRunning(),
+		PendingWrites:        p.History.PendingWrites(),
+		PendingNotifications: atomic.LoadInt64(&p.pendingNotifications),
+	}
+	if err := p.History.CompactError(); err != nil {
+		report.CompactionError = err.Error()
+	}
+
+	for _, c := range p.checkers {
+		check := healthCheck{
+			Group:      c.Group,
+			Check:      c.Name,
+			Interval:   c.Interval.String(),
+			StaleAfter: staleAfter(c).String(),
+		}
+		last := started
+		if items := p.History.GetGroupItems(c.Group, c.Name); len(items) > 0 {
+			check.LastResult = &items[0].CreatedAt
+			if items[0].CreatedAt.After(last) {
+				last = items[0].CreatedAt
+			}
+		}
+		if !started.IsZero() && now.Sub(last) > staleAfter(c) {
+			check.Stale = true
+			report.StaleChecks++
+		}
+		if p.canSee(req, c.Group, c.Name) {
+			report.Checks = append(report.Checks, check)
+		}
+	}
+
+	if !report.HistoryWriter {
+		report.Problems = append(report.Problems, "History writer is not running")
+	}
+	if report.StaleChecks > 0 {
+		report.Problems = append(report.Problems, fmt.Sprintf("%d checks have not produced a result in time", report.StaleChecks))
+	}
+	if readiness {
+		if !report.Started {
+			report.Problems = append(report.Problems, "Checkers have not been started")
+		}
+		if report.CompactionError != "" {
+			report.Problems = append(report.Problems, "Last compaction of the history file failed")
+		}
+		if report.PendingNotifications > maxNotificationBacklog {
+			report.Problems = append(report.Problems, fmt.Sprintf("%d notifications are still being sent", report.PendingNotifications))
+		}
+	}
+
+	report.Status = "ok"
+	if len(report.Problems) > 0 {
+		report.Status = "failing"
+	}
+	return report
+}
+
+// Serves '/healthz' and '/readyz'. They do not require authentication, but
+// only list the checks that the request can see.
+func (p *Patrol) serveHealth(res http.ResponseWriter, req *http.Request, readiness bool) {
+	id, err := p.auth.Authenticate(req)
+	if err != nil {
+		id = auth.Identity{}
+	}
+	req = req.WithContext(auth.WithIdentity(req.Context(), id))
+
+	report := p.health(req, readiness)
+	if id.Scope < auth.ScopeRead {
+		report.Checks = nil
+	}
+
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	res.Header().Set("Cache-Control", "no-store")
+	writeJSON(res, status, report)
+}
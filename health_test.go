@@ -0,0 +1,131 @@
+package patrol
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/karimsa/patrol/internal/checker"
+	"github.com/karimsa/patrol/internal/history"
+)
+
+func TestHealth(t *testing.T) {
+	removeTestDB("health-test.db")
+	defer removeTestDB("health-test.db")
+	historyFile, err := history.New(history.NewOptions{
+		File: "health-test.db",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	newChecker := func(name, visibility string) *checker.Checker {
+		return checker.New(&checker.Checker{
+			Group:      "api",
+			Name:       name,
+			Cmd:        "true",
+			History:    historyFile,
+			Interval:   1 * time.Minute,
+			Visibility: visibility,
+		})
+	}
+	p, err := New(CreatePatrolOptions{
+		Checkers: []*checker.Checker{
+			newChecker("Status", ""),
+			newChecker("Queue", "internal"),
+		},
+		APIToken: "secret",
+	}, historyFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	request := func(path, token string) (int, healthReport) {
+		req := httptest.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res := httptest.NewRecorder()
+		p.ServeHTTP(res, req)
+
+		var report healthReport
+		if err := json.Unmarshal(res.Body.Bytes(), &report); err != nil {
+			t.Error(fmt.Errorf("Expected %s to return JSON, got: %s", path, res.Body.String()))
+		}
+		return res.Code, report
+	}
+
+	if code, report := request("/healthz", ""); code != http.StatusOK || report.Status != "ok" {
+		t.Error(fmt.Errorf("Expected patrol to be live before it is started, got: %d %v", code, report.Problems))
+	}
+	if code, report := request("/readyz", ""); code != http.StatusServiceUnavailable || report.Started {
+		t.Error(fmt.Errorf("Expected patrol to not be ready before it is started, got: %d", code))
+	}
+
+	// Checks that have been running for an hour without results are stale
+	p.startedAt = time.Now().Add(-1 * time.Hour)
+	code, report := request("/healthz", "")
+	if code != http.StatusServiceUnavailable || report.StaleChecks != 2 {
+		t.Error(fmt.Errorf("Expected stale checks to fail liveness, got: %d %v", code, report.Problems))
+	}
+	if len(report.Checks) != 1 || report.Checks[0].Check != "Status" || !report.Checks[0].Stale || report.Checks[0].StaleAfter != "6m0s" {
+		t.Error(fmt.Errorf("Expected only public checks to be listed, got: %#v", report.Checks))
+	}
+	if _, report := request("/healthz", "secret"); len(report.Checks) != 2 {
+		t.Error(fmt.Errorf("Expected all checks to be listed with credentials, got: %#v", report.Checks))
+	}
+
+	for _, c := range p.checkers {
+		if _, err := historyFile.Append(history.Item{Group: c.Group, Name: c.Name, Type: "boolean", Status: "unhealthy"}); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if code, report := request("/readyz", ""); code != http.StatusOK || report.StaleChecks != 0 || report.Checks[0].LastResult == nil {
+		t.Error(fmt.Errorf("Expected patrol to be ready once checks report results, got: %d %v", code, report.Problems))
+	}
+
+	p.pendingNotifications = maxNotificationBacklog + 1
+	if code, _ := request("/readyz", ""); code != http.StatusServiceUnavailable {
+		t.Error(fmt.Errorf("Expected notification backlog to fail readiness, got: %d", code))
+	}
+	if code, _ := request("/healthz", ""); code != http.StatusOK {
+		t.Error(fmt.Errorf("Expected notification backlog to not fail liveness, got: %d", code))
+	}
+	p.pendingNotifications = 0
+
+	historyFile.Close()
+	if code, report := request("/healthz", ""); code != http.StatusServiceUnavailable || report.HistoryWriter {
+		t.Error(fmt.Errorf("Expected stopped history writer to fail liveness, got: %d", code))
+	}
+}
+
+func TestStaleAfter(t *testing.T) {
+	for _, test := range []struct {
+		maxRetries int
+		expected   time.Duration
+	}{
+		// A single attempt, without waiting to retry
+		{1, 1*time.Minute + 10*time.Second},
+		// Three attempts, with two waits in between
+		{3, 1*time.Minute + 3*10*time.Second + 2*30*time.Second},
+	} {
+		c := checker.New(&checker.Checker{
+			Group:         "api",
+			Name:          "Status",
+			Cmd:           "true",
+			Interval:      1 * time.Minute,
+			CmdTimeout:    10 * time.Second,
+			RetryInterval: 30 * time.Second,
+			MaxRetries:    test.maxRetries,
+		})
+		if stale := staleAfter(c); stale != test.expected {
+			t.Error(fmt.Errorf("Expected check with %d attempts to be stale after %s, got: %s", test.maxRetries, test.expected, stale))
+		}
+	}
+}
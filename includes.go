@@ -0,0 +1,173 @@
+package patrol
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config files that are included by the main config file can only define
+// services.
+type configFragment struct {
+	Services map[string]serviceConfig
+}
+
+// Returns the line at which a service is defined in the given config, or 0
+// if it cannot be found.
+func serviceLine(data []byte, name string) int {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	inServices := false
+	indent := -1
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		trimmed := strings.TrimLeft(text, " \t")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		depth := len(text) - len(trimmed)
+		if depth == 0 {
+			inServices = strings.HasPrefix(trimmed, "services:")
+			continue
+		}
+		if !inServices {
+			continue
+		}
+		if indent == -1 {
+			indent = depth
+		}
+		if depth != indent {
+			continue
+		}
+		for _, key := range []string{name, "'" + name + "'", `"` + name + `"`} {
+			if strings.HasPrefix(trimmed, key+":") {
+				return line
+			}
+		}
+	}
+	return 0
+}
+
+// Returns the location of a service, for use in errors.
+func serviceLocation(file string, data []byte, name string) string {
+	if line := serviceLine(data, name); line > 0 {
+		return fmt.Sprintf("%s:%d", file, line)
+	}
+	return file
+}
+
+// Returns the names of the services in the order that they are written in
+// the given config.
+func writtenServices(data []byte) ([]string, error) {
+	var services struct {
+		Services yaml.MapSlice
+	}
+	if err := yaml.Unmarshal(data, &services); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(services.Services))
+	for idx, item := range services.Services {
+		names[idx] = fmt.Sprint(item.Key)
+	}
+	return names, nil
+}
+
+// Reads a config file (or fragment of one), interpolating it and decoding it
+// into out. Errors are prefixed with the name of the file.
+func decodeConfigFile(file string, data []byte, out interface{}) ([]byte, []string, error) {
+	data, secrets, err := interpolate(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", file, err)
+	}
+	if err := yaml.UnmarshalStrict(data, out); err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return data, secrets, nil
+}
+
+// Parses the main config file, and merges in the services of the files that
+// it includes (and the files matching the extra patterns). Patterns in the
+// config are relative to the directory of the config file.
+func parseConfig(file string, data []byte, extraIncludes []string) (raw configRaw, err error) {
+	name := file
+	if name == "" {
+		name = "config"
+	}
+	data, raw.secrets, err = decodeConfigFile(name, data, &raw)
+	if err != nil {
+		return
+	}
+
+	if raw.serviceOrder, err = writtenServices(data); err != nil {
+		err = fmt.Errorf("%s: %s", name, err)
+		return
+	}
+	locations := make(map[string]string, len(raw.Services))
+	for group := range raw.Services {
+		locations[group] = serviceLocation(name, data, group)
+	}
+	if raw.Services == nil {
+		raw.Services = make(map[string]serviceConfig)
+	}
+
+	patterns := make([]string, 0, len(raw.Include)+len(extraIncludes))
+	for _, pattern := range raw.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(file), pattern)
+		}
+		patterns = append(patterns, pattern)
+	}
+	patterns = append(patterns, extraIncludes...)
+
+	seen := map[string]bool{filepath.Clean(file): true}
+	for _, pattern := range patterns {
+		var matches []string
+		if matches, err = filepath.Glob(pattern); err != nil {
+			err = fmt.Errorf("%s: invalid include '%s': %s", name, pattern, err)
+			return
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			err = fmt.Errorf("%s: included file does not exist: %s", name, pattern)
+			return
+		}
+
+		for _, include := range matches {
+			if seen[filepath.Clean(include)] {
+				continue
+			}
+			seen[filepath.Clean(include)] = true
+
+			var includeData []byte
+			if includeData, err = ioutil.ReadFile(include); err != nil {
+				return
+			}
+			var fragment configFragment
+			var secrets []string
+			if includeData, secrets, err = decodeConfigFile(include, includeData, &fragment); err != nil {
+				return
+			}
+			raw.secrets = append(raw.secrets, secrets...)
+			var order []string
+			if order, err = writtenServices(includeData); err != nil {
+				err = fmt.Errorf("%s: %s", include, err)
+				return
+			}
+			raw.serviceOrder = append(raw.serviceOrder, order...)
+
+			for group, service := range fragment.Services {
+				location := serviceLocation(include, includeData, group)
+				if previous, ok := locations[group]; ok {
+					err = fmt.Errorf("%s: service '%s' is already defined at %s", location, group, previous)
+					return
+				}
+				locations[group] = location
+				raw.Services[group] = service
+			}
+		}
+	}
+	return
+}
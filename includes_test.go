@@ -0,0 +1,100 @@
+package patrol
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigIncludes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "patrol-includes")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	removeTestDB("includes-test.db")
+	defer removeTestDB("includes-test.db")
+
+	writeFiles := func(files map[string]string) {
+		for name, content := range files {
+			path := filepath.Join(dir, name)
+			os.MkdirAll(filepath.Dir(path), 0755)
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+	writeFiles(map[string]string{
+		"patrol.yml": `
+db: includes-test.db
+include: [services/*.yml]
+services:
+  API:
+    checks:
+    - name: API Status
+      cmd: 'true'
+`,
+		"services/web.yml": `
+services:
+  Web:
+    checks:
+    - name: Homepage
+      cmd: 'true'
+`,
+		"conf.d/db.yaml": `
+# Owned by the database team
+services:
+  Database:
+    checks:
+    - name: Replication
+      cmd: 'true'
+`,
+	})
+
+	p, raw, err := FromConfigFiles(filepath.Join(dir, "patrol.yml"), filepath.Join(dir, "conf.d"), nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	p.Close()
+	for _, group := range []string{"API", "Web", "Database"} {
+		if !p.hasGroup(group) {
+			t.Error(fmt.Errorf("Expected service '%s' to be merged into the config, got: %v", group, raw.Services))
+		}
+	}
+
+	for _, c := range []struct {
+		files    map[string]string
+		expected string
+	}{
+		{
+			map[string]string{"conf.d/web.yaml": "services:\n  Web:\n    checks:\n    - name: Other\n      cmd: 'true'\n"},
+			"conf.d/web.yaml:2: service 'Web' is already defined at " + filepath.Join(dir, "services/web.yml") + ":3",
+		},
+		{
+			map[string]string{"conf.d/web.yaml": "services:\n  Other:\n    checks:\n    - name: Other\n      command: 'true'\n"},
+			"conf.d/web.yaml: yaml: unmarshal errors:\n  line 5: field command not found",
+		},
+		{
+			map[string]string{"conf.d/web.yaml": "db: other.db\n"},
+			"conf.d/web.yaml: yaml: unmarshal errors:\n  line 1: field db not found",
+		},
+	} {
+		writeFiles(c.files)
+		_, _, err := FromConfigFiles(filepath.Join(dir, "patrol.yml"), filepath.Join(dir, "conf.d"), nil)
+		if err == nil || !strings.Contains(err.Error(), c.expected) {
+			t.Error(fmt.Errorf("Expected error containing '%s', got: %v", c.expected, err))
+		}
+	}
+
+	if _, _, err := FromConfigFiles(filepath.Join(dir, "patrol.yml"), filepath.Join(dir, "missing.d"), nil); err == nil || !strings.Contains(err.Error(), "config directory does not exist") {
+		t.Error(fmt.Errorf("Expected missing config directory to be rejected, got: %v", err))
+	}
+	if _, _, err := FromConfig([]byte("db: includes-test.db\ninclude: [missing.yml]\n"), nil); err == nil || !strings.Contains(err.Error(), "included file does not exist") {
+		t.Error(fmt.Errorf("Expected missing include to be rejected, got: %v", err))
+	}
+}
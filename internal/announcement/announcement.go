@@ -0,0 +1,203 @@
+package announcement
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Statuses that an announcement can go through, in order.
+var Statuses = []string{"investigating", "identified", "monitoring", "resolved"}
+
+func validStatus(status string) bool {
+	for _, s := range Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Update is a single message posted to an announcement.
+type Update struct {
+	Status    string
+	Message   string
+	CreatedAt time.Time
+}
+
+// Announcement is an incident that was posted manually by an operator, such
+// as an outage of a third-party vendor or a planned migration.
+type Announcement struct {
+	ID       string
+	Title    string
+	Services []string
+	Status   string
+
+	// Updates to the announcement, newest first
+	Updates []Update
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (a Announcement) String() string {
+	return fmt.Sprintf("Announcement{ID: %s, Title: '%s', Services: %v, Status: %s, Updates: %d, UpdatedAt: %s}", a.ID, a.Title, a.Services, a.Status, len(a.Updates), a.UpdatedAt.Format(time.RFC3339))
+}
+
+// Resolved returns true if the latest status of the announcement is resolved.
+func (a Announcement) Resolved() bool {
+	return a.Status == "resolved"
+}
+
+// Store keeps track of announcements and persists them to a JSON file.
+type Store struct {
+	path          string
+	rwMux         *sync.RWMutex
+	announcements []Announcement
+}
+
+// Open loads announcements from the given file. A missing file is treated as
+// an empty store.
+func Open(path string) (*Store, error) {
+	store := &Store{
+		path:  path,
+		rwMux: &sync.RWMutex{},
+	}
+	buffer, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buffer, &store.announcements); err != nil {
+		return nil, fmt.Errorf("Failed to parse %s: %s", path, err)
+	}
+	return store, nil
+}
+
+// Must be called with the write lock held.
+func (store *Store) save() error {
+	sort.SliceStable(store.announcements, func(i, j int) bool {
+		return store.announcements[i].UpdatedAt.After(store.announcements[j].UpdatedAt)
+	})
+
+	buffer, err := json.Marshal(store.announcements)
+	if err != nil {
+		return err
+	}
+	tmpPath := store.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, buffer, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, store.path)
+}
+
+// Create posts a new announcement with an initial update.
+func (store *Store) Create(title string, services []string, status, message string) (Announcement, error) {
+	if title == "" {
+		return Announcement{}, fmt.Errorf("Announcement must have a title")
+	}
+	if status == "" {
+		status = Statuses[0]
+	}
+	if !validStatus(status) {
+		return Announcement{}, fmt.Errorf("Invalid status '%s', must be one of: %v", status, Statuses)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Announcement{}, err
+	}
+	now := time.Now()
+	a := Announcement{
+		ID:        hex.EncodeToString(id),
+		Title:     title,
+		Services:  services,
+		Status:    status,
+		Updates:   []Update{{Status: status, Message: message, CreatedAt: now}},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	store.rwMux.Lock()
+	defer store.rwMux.Unlock()
+	store.announcements = append(store.announcements, a)
+	return a, store.save()
+}
+
+// Update posts a new message to an existing announcement. An empty status
+// keeps the current status of the announcement.
+func (store *Store) Update(id, status, message string) (Announcement, bool, error) {
+	if status != "" && !validStatus(status) {
+		return Announcement{}, false, fmt.Errorf("Invalid status '%s', must be one of: %v", status, Statuses)
+	}
+
+	store.rwMux.Lock()
+	defer store.rwMux.Unlock()
+
+	for idx := range store.announcements {
+		a := &store.announcements[idx]
+		if a.ID == id {
+			if status == "" {
+				status = a.Status
+			}
+			now := time.Now()
+			a.Status = status
+			a.UpdatedAt = now
+			a.Updates = append([]Update{{Status: status, Message: message, CreatedAt: now}}, a.Updates...)
+			updated := *a
+			return updated, true, store.save()
+		}
+	}
+	return Announcement{}, false, nil
+}
+
+// Delete removes an announcement entirely. It returns false if no such
+// announcement exists.
+func (store *Store) Delete(id string) (bool, error) {
+	store.rwMux.Lock()
+	defer store.rwMux.Unlock()
+
+	for idx, a := range store.announcements {
+		if a.ID == id {
+			store.announcements = append(store.announcements[:idx], store.announcements[idx+1:]...)
+			return true, store.save()
+		}
+	}
+	return false, nil
+}
+
+// Get returns the announcement with the given ID.
+func (store *Store) Get(id string) (Announcement, bool) {
+	store.rwMux.RLock()
+	defer store.rwMux.RUnlock()
+
+	for _, a := range store.announcements {
+		if a.ID == id {
+			return a, true
+		}
+	}
+	return Announcement{}, false
+}
+
+// List returns announcements that are unresolved or were resolved after the
+// given time, most recently updated first.
+func (store *Store) List(resolvedSince time.Time) []Announcement {
+	store.rwMux.RLock()
+	defer store.rwMux.RUnlock()
+
+	list := make([]Announcement, 0, len(store.announcements))
+	for _, a := range store.announcements {
+		if !a.Resolved() || a.UpdatedAt.After(resolvedSince) {
+			list = append(list, a)
+		}
+	}
+	return list
+}
@@ -0,0 +1,81 @@
+package announcement
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAnnouncements(t *testing.T) {
+	os.Remove("announcement-test.json")
+	defer os.Remove("announcement-test.json")
+
+	store, err := Open("announcement-test.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := store.Create("", nil, "", ""); err == nil {
+		t.Error(fmt.Errorf("Announcements without a title should be rejected"))
+		return
+	}
+	if _, err := store.Create("Vendor outage", nil, "broken", ""); err == nil {
+		t.Error(fmt.Errorf("Announcements with an invalid status should be rejected"))
+		return
+	}
+
+	a, err := store.Create("Vendor outage", []string{"payments"}, "", "Our payment provider is down")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if a.Status != "investigating" {
+		t.Error(fmt.Errorf("Expected default status to be investigating, got: %s", a.Status))
+		return
+	}
+	if _, ok, err := store.Update(a.ID, "identified", "Waiting on vendor"); err != nil || !ok {
+		t.Error(fmt.Errorf("Failed to update announcement: %v", err))
+		return
+	}
+	if _, ok, _ := store.Update("missing", "", "Nothing"); ok {
+		t.Error(fmt.Errorf("Updating a missing announcement should fail"))
+		return
+	}
+
+	store, err = Open("announcement-test.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	a, ok := store.Get(a.ID)
+	if !ok {
+		t.Error(fmt.Errorf("Announcement was not persisted"))
+		return
+	}
+	if a.Status != "identified" || len(a.Updates) != 2 || a.Updates[0].Message != "Waiting on vendor" {
+		t.Error(fmt.Errorf("Updates were not persisted: %s", a))
+		return
+	}
+
+	if _, _, err := store.Update(a.ID, "resolved", "Vendor has recovered"); err != nil {
+		t.Error(err)
+		return
+	}
+	if list := store.List(time.Now().Add(-time.Hour)); len(list) != 1 {
+		t.Error(fmt.Errorf("Recently resolved announcements should be listed, got: %v", list))
+		return
+	}
+	if list := store.List(time.Now().Add(time.Hour)); len(list) != 0 {
+		t.Error(fmt.Errorf("Resolved announcements should not be listed, got: %v", list))
+		return
+	}
+
+	if ok, err := store.Delete(a.ID); err != nil || !ok {
+		t.Error(fmt.Errorf("Failed to delete announcement: %v", err))
+		return
+	}
+	if _, ok := store.Get(a.ID); ok {
+		t.Error(fmt.Errorf("Announcement was not deleted"))
+	}
+}
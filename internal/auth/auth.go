@@ -0,0 +1,247 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Scope is the level of access granted to a request. Each scope includes all
+// of the scopes before it.
+type Scope int
+
+const (
+	// No access at all
+	ScopeNone Scope = iota
+
+	// Viewing the status page and reading from the API
+	ScopeRead
+
+	// Acknowledging failures, creating silences, and posting announcements
+	ScopeWrite
+
+	// Removing silences and announcements
+	ScopeAdmin
+)
+
+var scopeNames = []string{"none", "read", "write", "admin"}
+
+func (s Scope) String() string {
+	if int(s) < len(scopeNames) {
+		return scopeNames[s]
+	}
+	return fmt.Sprintf("Scope(%d)", int(s))
+}
+
+// ParseScope parses the name of a scope.
+func ParseScope(str string) (Scope, error) {
+	for s, name := range scopeNames {
+		if name == str {
+			return Scope(s), nil
+		}
+	}
+	return ScopeNone, fmt.Errorf("Invalid scope '%s', must be one of: %v", str, scopeNames)
+}
+
+// User that can log in using HTTP Basic authentication.
+type User struct {
+	Username     string
+	PasswordHash string
+	Scope        Scope
+}
+
+// Token that can be given as a bearer token in the 'Authorization' header.
+type Token struct {
+	Name  string
+	Token string
+	Scope Scope
+}
+
+// ProxyAuth trusts a header set by a reverse proxy to identify the user, as
+// long as the request comes from one of the trusted proxies.
+type ProxyAuth struct {
+	Header         string
+	TrustedProxies []*net.IPNet
+	Scope          Scope
+}
+
+// Options used to create an authenticator.
+type Options struct {
+	Users  []User
+	Tokens []Token
+	Proxy  *ProxyAuth
+
+	// Scope granted to requests without credentials. If 'Anonymous' is nil,
+	// anonymous requests can read unless any users, tokens, or proxy auth
+	// are configured.
+	Anonymous *Scope
+}
+
+// Identity is the result of authenticating a request.
+type Identity struct {
+	Name   string
+	Method string
+	Scope  Scope
+}
+
+// Anonymous returns true if the request did not carry any credentials.
+func (id Identity) Anonymous() bool {
+	return id.Method == ""
+}
+
+// Authenticator verifies the credentials of requests.
+type Authenticator struct {
+	users     map[string]User
+	tokens    []Token
+	proxy     *ProxyAuth
+	anonymous Scope
+
+	// Hashes of passwords that were already verified, since verifying
+	// bcrypt hashes is slow by design
+	verifiedMux *sync.RWMutex
+	verified    map[string][32]byte
+}
+
+// New validates the given options and creates an authenticator.
+func New(options Options) (*Authenticator, error) {
+	a := &Authenticator{
+		users:       make(map[string]User, len(options.Users)),
+		tokens:      options.Tokens,
+		proxy:       options.Proxy,
+		anonymous:   ScopeRead,
+		verifiedMux: &sync.RWMutex{},
+		verified:    make(map[string][32]byte),
+	}
+
+	for _, user := range options.Users {
+		if user.Username == "" || strings.Contains(user.Username, ":") {
+			return nil, fmt.Errorf("Invalid username: '%s'", user.Username)
+		}
+		if _, ok := a.users[user.Username]; ok {
+			return nil, fmt.Errorf("Duplicate user: '%s'", user.Username)
+		}
+		if _, err := bcrypt.Cost([]byte(user.PasswordHash)); err != nil {
+			return nil, fmt.Errorf("Password of user '%s' is not a valid bcrypt hash: %s", user.Username, err)
+		}
+		a.users[user.Username] = user
+	}
+	for _, token := range options.Tokens {
+		if token.Token == "" {
+			return nil, fmt.Errorf("Token '%s' cannot be empty", token.Name)
+		}
+	}
+	if options.Proxy != nil {
+		if options.Proxy.Header == "" {
+			return nil, fmt.Errorf("Proxy auth must specify a header")
+		}
+		if len(options.Proxy.TrustedProxies) == 0 {
+			return nil, fmt.Errorf("Proxy auth must specify at least one trusted proxy")
+		}
+	}
+
+	if options.Anonymous != nil {
+		a.anonymous = *options.Anonymous
+	} else if a.Configured() {
+		a.anonymous = ScopeNone
+	}
+	return a, nil
+}
+
+// Configured returns true if there is any way for a request to authenticate.
+func (a *Authenticator) Configured() bool {
+	return len(a.users) > 0 || len(a.tokens) > 0 || a.proxy != nil
+}
+
+// HasUsers returns true if users can log in with HTTP Basic authentication.
+func (a *Authenticator) HasUsers() bool {
+	return len(a.users) > 0
+}
+
+func (a *Authenticator) verifyPassword(user User, password string) bool {
+	hash := sha256.Sum256([]byte(user.PasswordHash + ":" + password))
+
+	a.verifiedMux.RLock()
+	verified, ok := a.verified[user.Username]
+	a.verifiedMux.RUnlock()
+	if ok && subtle.ConstantTimeCompare(hash[:], verified[:]) == 1 {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return false
+	}
+	a.verifiedMux.Lock()
+	a.verified[user.Username] = hash
+	a.verifiedMux.Unlock()
+	return true
+}
+
+// FromTrustedProxy returns true if the request came directly from one of the
+// given networks.
+func FromTrustedProxy(req *http.Request, networks []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate returns the identity of the request. An error is returned if
+// the request carries invalid credentials.
+func (a *Authenticator) Authenticate(req *http.Request) (Identity, error) {
+	if header := req.Header.Get("Authorization"); header != "" {
+		if strings.HasPrefix(header, "Bearer ") {
+			given := []byte(strings.TrimPrefix(header, "Bearer "))
+			for _, token := range a.tokens {
+				if subtle.ConstantTimeCompare(given, []byte(token.Token)) == 1 {
+					return Identity{Name: token.Name, Method: "token", Scope: token.Scope}, nil
+				}
+			}
+			return Identity{}, fmt.Errorf("Invalid API token")
+		}
+
+		if username, password, ok := req.BasicAuth(); ok {
+			if user, ok := a.users[username]; ok && a.verifyPassword(user, password) {
+				return Identity{Name: username, Method: "basic", Scope: user.Scope}, nil
+			}
+			return Identity{}, fmt.Errorf("Invalid username or password")
+		}
+		return Identity{}, fmt.Errorf("Unsupported authorization scheme")
+	}
+
+	if a.proxy != nil && FromTrustedProxy(req, a.proxy.TrustedProxies) {
+		if name := req.Header.Get(a.proxy.Header); name != "" {
+			return Identity{Name: name, Method: "proxy", Scope: a.proxy.Scope}, nil
+		}
+	}
+	return Identity{Scope: a.anonymous}, nil
+}
+
+type contextKey struct{}
+
+// WithIdentity returns a copy of the context carrying the given identity.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the identity stored in the context, if any.
+func FromContext(ctx context.Context) Identity {
+	id, _ := ctx.Value(contextKey{}).(Identity)
+	return id
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthenticate(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+	a, err := New(Options{
+		Users:  []User{{Username: "alice", PasswordHash: string(hash), Scope: ScopeWrite}},
+		Tokens: []Token{{Name: "ci", Token: "secret", Scope: ScopeRead}},
+		Proxy: &ProxyAuth{
+			Header:         "X-Forwarded-User",
+			TrustedProxies: []*net.IPNet{trusted},
+			Scope:          ScopeAdmin,
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, c := range []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		basicAuth  []string
+		scope      Scope
+		err        bool
+	}{
+		{name: "anonymous", scope: ScopeNone},
+		{name: "valid password", basicAuth: []string{"alice", "hunter2"}, scope: ScopeWrite},
+		{name: "cached password", basicAuth: []string{"alice", "hunter2"}, scope: ScopeWrite},
+		{name: "wrong password", basicAuth: []string{"alice", "hunter3"}, err: true},
+		{name: "unknown user", basicAuth: []string{"bob", "hunter2"}, err: true},
+		{name: "valid token", headers: map[string]string{"Authorization": "Bearer secret"}, scope: ScopeRead},
+		{name: "invalid token", headers: map[string]string{"Authorization": "Bearer nope"}, err: true},
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:1234", headers: map[string]string{"X-Forwarded-User": "carol"}, scope: ScopeAdmin},
+		{name: "untrusted proxy", remoteAddr: "192.168.1.1:1234", headers: map[string]string{"X-Forwarded-User": "carol"}, scope: ScopeNone},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if c.remoteAddr != "" {
+			req.RemoteAddr = c.remoteAddr
+		}
+		for key, value := range c.headers {
+			req.Header.Set(key, value)
+		}
+		if c.basicAuth != nil {
+			req.SetBasicAuth(c.basicAuth[0], c.basicAuth[1])
+		}
+
+		id, err := a.Authenticate(req)
+		if c.err != (err != nil) {
+			t.Error(fmt.Errorf("%s: unexpected error: %v", c.name, err))
+		} else if !c.err && id.Scope != c.scope {
+			t.Error(fmt.Errorf("%s: expected scope %s, got: %s", c.name, c.scope, id.Scope))
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Options{Users: []User{{Username: "alice", PasswordHash: "plaintext"}}}); err == nil {
+		t.Error(fmt.Errorf("Passwords that are not bcrypt hashes should be rejected"))
+	}
+	if _, err := New(Options{Proxy: &ProxyAuth{Header: "X-Forwarded-User"}}); err == nil {
+		t.Error(fmt.Errorf("Proxy auth without trusted proxies should be rejected"))
+	}
+
+	a, err := New(Options{Tokens: []Token{{Name: "ci", Token: "secret", Scope: ScopeAdmin}}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if id, _ := a.Authenticate(httptest.NewRequest("GET", "/", nil)); id.Scope != ScopeNone {
+		t.Error(fmt.Errorf("Anonymous requests should not be able to read when tokens are configured"))
+	}
+
+	if a, err = New(Options{}); err != nil {
+		t.Error(err)
+		return
+	}
+	if id, _ := a.Authenticate(httptest.NewRequest("GET", "/", nil)); id.Scope != ScopeRead {
+		t.Error(fmt.Errorf("Anonymous requests should be able to read when no auth is configured"))
+	}
+}
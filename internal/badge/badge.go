@@ -0,0 +1,106 @@
+package badge
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"text/template"
+)
+
+// Colors used by badges, matching those of shields.io.
+const (
+	ColorBrightGreen = "#4c1"
+	ColorGreen       = "#97ca00"
+	ColorYellow      = "#dfb317"
+	ColorOrange      = "#fe7d37"
+	ColorRed         = "#e05d44"
+	ColorBlue        = "#007ec6"
+	ColorGrey        = "#9f9f9f"
+)
+
+// Approximate widths of characters in 11px Verdana, which is the font used by
+// badges. Characters that are not listed are assumed to be of average width.
+var charWidths = map[rune]float64{
+	' ': 3.9, '!': 4.7, '%': 12, '(': 5.5, ')': 5.5, ',': 4.4, '-': 5.1, '.': 4.4, '/': 5.9, ':': 5.1,
+	'I': 4.8, 'J': 5.3, 'M': 9.5, 'W': 11, 'f': 3.9, 'i': 3.1, 'j': 3.4, 'l': 3.1, 'm': 10.7, 'r': 4.7, 't': 4.4, 'w': 9,
+}
+
+func textWidth(str string) int {
+	width := 0.0
+	for _, c := range str {
+		if w, ok := charWidths[c]; ok {
+			width += w
+		} else if c >= 'A' && c <= 'Z' {
+			width += 7.6
+		} else {
+			width += 6.8
+		}
+	}
+	return int(width + 0.5)
+}
+
+var badgeTemplate = template.Must(template.New("badge").Funcs(template.FuncMap{
+	"half": func(n int) int { return n / 2 },
+	"plus": func(a, b int) int { return a + b },
+	"x10":  func(n int) int { return n * 10 },
+	"xml":  html.EscapeString,
+}).Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="20" role="img" aria-label="{{xml .Label}}: {{xml .Message}}">` +
+	`<title>{{xml .Label}}: {{xml .Message}}</title>` +
+	`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>` +
+	`<clipPath id="r"><rect width="{{.Width}}" height="20" rx="3" fill="#fff"/></clipPath>` +
+	`<g clip-path="url(#r)"><rect width="{{.LabelWidth}}" height="20" fill="#555"/><rect x="{{.LabelWidth}}" width="{{.MessageWidth}}" height="20" fill="{{xml .Color}}"/><rect width="{{.Width}}" height="20" fill="url(#s)"/></g>` +
+	`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="110" text-rendering="geometricPrecision">` +
+	`<text x="{{x10 (half .LabelWidth)}}" y="150" fill="#010101" fill-opacity=".3" transform="scale(.1)">{{xml .Label}}</text>` +
+	`<text x="{{x10 (half .LabelWidth)}}" y="140" transform="scale(.1)">{{xml .Label}}</text>` +
+	`<text x="{{x10 (plus .LabelWidth (half .MessageWidth))}}" y="150" fill="#010101" fill-opacity=".3" transform="scale(.1)">{{xml .Message}}</text>` +
+	`<text x="{{x10 (plus .LabelWidth (half .MessageWidth))}}" y="140" transform="scale(.1)">{{xml .Message}}</text>` +
+	`</g></svg>`))
+
+// Render returns a flat badge with the label on the left and the message on
+// a background of the given color on the right.
+func Render(label, message, color string) []byte {
+	labelWidth := textWidth(label) + 10
+	messageWidth := textWidth(message) + 10
+
+	buffer := bytes.Buffer{}
+	if err := badgeTemplate.Execute(&buffer, map[string]interface{}{
+		"Label":        label,
+		"Message":      message,
+		"Color":        color,
+		"LabelWidth":   labelWidth,
+		"MessageWidth": messageWidth,
+		"Width":        labelWidth + messageWidth,
+	}); err != nil {
+		// The template is static, so this can only be a programming error
+		panic(fmt.Errorf("Failed to render badge: %s", err))
+	}
+	return buffer.Bytes()
+}
+
+// StatusColor returns the color of a badge showing a check status.
+func StatusColor(status string) string {
+	switch status {
+	case "healthy":
+		return ColorBrightGreen
+	case "recovered":
+		return ColorOrange
+	case "unhealthy":
+		return ColorRed
+	case "maintenance":
+		return ColorBlue
+	}
+	return ColorGrey
+}
+
+// UptimeColor returns the color of a badge showing an uptime percentage.
+func UptimeColor(percent float64) string {
+	switch {
+	case percent >= 99.9:
+		return ColorBrightGreen
+	case percent >= 99:
+		return ColorGreen
+	case percent >= 95:
+		return ColorYellow
+	}
+	return ColorRed
+}
@@ -0,0 +1,29 @@
+package badge
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	svg := string(Render("API <v2>", "99.95%", UptimeColor(99.95)))
+	if err := xml.Unmarshal([]byte(svg), new(interface{})); err != nil {
+		t.Error(fmt.Errorf("Badge is not valid XML: %s\n%s", err, svg))
+		return
+	}
+	if !strings.Contains(svg, "API &lt;v2&gt;") {
+		t.Error(fmt.Errorf("Label was not escaped: %s", svg))
+		return
+	}
+	if !strings.Contains(svg, ColorBrightGreen) {
+		t.Error(fmt.Errorf("Badge does not use the given color: %s", svg))
+		return
+	}
+
+	short, long := Render("a", "b", ColorGrey), Render("a", "unhealthy", ColorGrey)
+	if len(short) >= len(long) || textWidth("b") >= textWidth("unhealthy") {
+		t.Error(fmt.Errorf("Badge width should grow with the message"))
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/karimsa/patrol/internal/history"
 	"github.com/karimsa/patrol/internal/logger"
+	"github.com/karimsa/patrol/internal/maintenance"
 )
 
 var (
@@ -43,8 +44,15 @@ type Checker struct {
 	CmdTimeout    time.Duration
 	MaxRetries    int
 	RetryInterval time.Duration
+	Labels        map[string]string
+	Maintenance   maintenance.Windows
 	History       *history.File
 
+	// Checks are shown to unauthenticated visitors unless their visibility
+	// is 'internal'. Public checks can still hide their output and errors.
+	Visibility string
+	HideOutput bool
+
 	logger   logger.Logger
 	doneChan chan bool
 	wg       *sync.WaitGroup
@@ -77,6 +85,11 @@ func (c *Checker) GetName() string {
 	return c.Name
 }
 
+// Public returns true if the check is shown to unauthenticated visitors.
+func (c *Checker) Public() bool {
+	return c.Visibility != "internal"
+}
+
 func (c *Checker) SetLogLevel(level logger.LogLevel) {
 	c.logger = logger.New(
 		level,
@@ -96,10 +109,15 @@ func (c *Checker) Check() history.Item {
 			}
 		}
 		item = c.check()
+		item.Attempts = i + 1
 		if item.Status != "unhealthy" {
 			return item
 		}
 	}
+	if _, ok := c.Maintenance.Active(item.CreatedAt); ok {
+		c.logger.Infof("Check failed during maintenance window")
+		item.Status = "maintenance"
+	}
 	return item
 }
 
@@ -171,6 +189,12 @@ type eventReceiver interface {
 	OnCheckerStatus(status, service, check string)
 }
 
+// Receivers can optionally implement this interface to be given every item
+// that is written to history, before 'OnCheckerStatus' is called.
+type itemReceiver interface {
+	OnCheckerItem(item history.Item)
+}
+
 func (c *Checker) Start(receiver eventReceiver) error {
 	c.wg.Add(1)
 	go func() {
@@ -193,6 +217,9 @@ func (c *Checker) Start(receiver eventReceiver) error {
 				if err != nil {
 					panic(err)
 				}
+				if r, ok := receiver.(itemReceiver); ok {
+					r.OnCheckerItem(item)
+				}
 				if receiver != nil {
 					receiver.OnCheckerStatus(item.Status, item.Group, item.Name)
 				}
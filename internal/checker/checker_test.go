@@ -132,4 +132,8 @@ func TestRetries(t *testing.T) {
 		t.Error(fmt.Errorf("Check not retried enough times: %#v", lines))
 		return
 	}
+	if items[0].Attempts != checker.MaxRetries {
+		t.Error(fmt.Errorf("Expected %d attempts to be recorded, got: %d", checker.MaxRetries, items[0].Attempts))
+		return
+	}
 }
@@ -0,0 +1,176 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Event is a single message sent to subscribers.
+type Event struct {
+	ID    uint64
+	Type  string
+	Group string
+	Check string
+	Data  []byte
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("Event{ID: %d, Type: %s, Group: %s, Check: %s}", e.ID, e.Type, e.Group, e.Check)
+}
+
+// Matches returns true if the event belongs to the given group and check.
+// Empty values match all groups or checks.
+func (e Event) Matches(group, check string) bool {
+	return (group == "" || e.Group == group) && (check == "" || e.Check == check)
+}
+
+// Subscription receives events published after it was created, as well as
+// any buffered events that it asked to resume from.
+type Subscription struct {
+	Group, Check string
+
+	// Buffered events that were published after the requested ID
+	Missed []Event
+
+	// Closed when the broker is closed, or if the subscriber falls too far
+	// behind (in which case it should resubscribe from its last event)
+	Events chan Event
+}
+
+// Broker fans out published events to subscribers, and keeps a buffer of
+// recent events so that subscribers can resume after reconnecting.
+type Broker struct {
+	mux         *sync.Mutex
+	nextID      uint64
+	firstID     uint64
+	buffer      []Event
+	bufferSize  int
+	subscribers map[*Subscription]bool
+	statuses    map[string]string
+	closed      bool
+}
+
+// Number of events that can be queued for a subscriber before it is dropped.
+const subscriberQueueSize = 64
+
+// New creates a broker that buffers up to 'bufferSize' events. Event IDs start
+// from the current time, so that IDs given by subscribers of a previous
+// process never match new events.
+func New(bufferSize int) *Broker {
+	firstID := uint64(time.Now().UnixNano())
+	return &Broker{
+		mux:         &sync.Mutex{},
+		nextID:      firstID,
+		firstID:     firstID,
+		bufferSize:  bufferSize,
+		subscribers: make(map[*Subscription]bool),
+		statuses:    make(map[string]string),
+	}
+}
+
+// Publish sends an event with the given data encoded as JSON to all matching
+// subscribers.
+func (b *Broker) Publish(eventType, group, check string, data interface{}) error {
+	buffer, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.closed {
+		return nil
+	}
+
+	event := Event{
+		ID:    b.nextID,
+		Type:  eventType,
+		Group: group,
+		Check: check,
+		Data:  buffer,
+	}
+	b.nextID++
+	b.buffer = append(b.buffer, event)
+	if len(b.buffer) > b.bufferSize {
+		b.buffer = b.buffer[len(b.buffer)-b.bufferSize:]
+	}
+
+	for sub := range b.subscribers {
+		if !event.Matches(sub.Group, sub.Check) {
+			continue
+		}
+		select {
+		case sub.Events <- event:
+		default:
+			delete(b.subscribers, sub)
+			close(sub.Events)
+		}
+	}
+	return nil
+}
+
+// Transition records the latest status of a check, and returns the previous
+// status if it changed. The first status seen for a check is not considered
+// a transition.
+func (b *Broker) Transition(group, check, status string) (string, bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	key := group + "|" + check
+	prev, ok := b.statuses[key]
+	b.statuses[key] = status
+	return prev, ok && prev != status
+}
+
+// Subscribe creates a subscription to events matching the given group and
+// check. If 'lastID' is the ID of an event published by this broker, the
+// buffered events that followed it are returned in 'Missed'.
+func (b *Broker) Subscribe(lastID uint64, group, check string) *Subscription {
+	sub := &Subscription{
+		Group:  group,
+		Check:  check,
+		Events: make(chan Event, subscriberQueueSize),
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.closed {
+		close(sub.Events)
+		return sub
+	}
+	if lastID >= b.firstID && lastID < b.nextID {
+		for _, event := range b.buffer {
+			if event.ID > lastID && event.Matches(group, check) {
+				sub.Missed = append(sub.Missed, event)
+			}
+		}
+	}
+	b.subscribers[sub] = true
+	return sub
+}
+
+// Unsubscribe stops sending events to the subscription.
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.subscribers[sub] {
+		delete(b.subscribers, sub)
+		close(sub.Events)
+	}
+}
+
+// Close ends all subscriptions. Events published afterwards are dropped.
+func (b *Broker) Close() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		close(sub.Events)
+	}
+	b.subscribers = make(map[*Subscription]bool)
+}
@@ -0,0 +1,71 @@
+package events
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestResume(t *testing.T) {
+	b := New(3)
+	first := b.Subscribe(0, "", "")
+	for i := 0; i < 5; i++ {
+		if err := b.Publish("item", "foo", fmt.Sprintf("check %d", i), i); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	var ids []uint64
+	for i := 0; i < 5; i++ {
+		ids = append(ids, (<-first.Events).ID)
+	}
+
+	// Only the last three events are buffered
+	if sub := b.Subscribe(ids[2], "", ""); len(sub.Missed) != 2 || sub.Missed[0].ID != ids[3] {
+		t.Error(fmt.Errorf("Expected events after %d to be replayed, got: %v", ids[2], sub.Missed))
+		return
+	}
+	if sub := b.Subscribe(ids[1], "", "check 4"); len(sub.Missed) != 1 || string(sub.Missed[0].Data) != "4" {
+		t.Error(fmt.Errorf("Expected filtered events to be replayed, got: %v", sub.Missed))
+		return
+	}
+	if sub := b.Subscribe(ids[0]-1000, "", ""); len(sub.Missed) != 0 {
+		t.Error(fmt.Errorf("Events should not be replayed for unknown IDs, got: %v", sub.Missed))
+		return
+	}
+
+	b.Close()
+	if _, ok := <-first.Events; ok {
+		t.Error(fmt.Errorf("Subscriptions should be closed with the broker"))
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	b := New(10)
+	sub := b.Subscribe(0, "foo", "")
+	for i := 0; i <= subscriberQueueSize; i++ {
+		b.Publish("item", "foo", "bar", i)
+		b.Publish("item", "other", "bar", i)
+	}
+
+	n := 0
+	for range sub.Events {
+		n++
+	}
+	if n != subscriberQueueSize {
+		t.Error(fmt.Errorf("Expected subscriber to be dropped after %d events, got: %d", subscriberQueueSize, n))
+	}
+}
+
+func TestTransition(t *testing.T) {
+	b := New(10)
+	if _, changed := b.Transition("foo", "bar", "healthy"); changed {
+		t.Error(fmt.Errorf("First status should not be a transition"))
+	}
+	if _, changed := b.Transition("foo", "bar", "healthy"); changed {
+		t.Error(fmt.Errorf("Same status should not be a transition"))
+	}
+	if prev, changed := b.Transition("foo", "bar", "unhealthy"); !changed || prev != "healthy" {
+		t.Error(fmt.Errorf("Expected transition from healthy, got: %s", prev))
+	}
+}
@@ -0,0 +1,124 @@
+package feed
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Entry is a single item of a feed.
+type Entry struct {
+	ID      string
+	Title   string
+	Link    string
+	Summary string
+	Updated time.Time
+}
+
+// Feed is a list of entries that can be rendered as either Atom or RSS.
+type Feed struct {
+	ID      string
+	Title   string
+	Link    string
+	Updated time.Time
+	Entries []Entry
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary,omitempty"`
+	Updated string   `xml:"updated"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Links   []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  string      `xml:"author>name"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// Atom renders the feed in the Atom format. 'self' is the URL of the feed
+// itself.
+func (f Feed) Atom(self string) ([]byte, error) {
+	out := atomFeed{
+		ID:    f.ID,
+		Title: f.Title,
+		Links: []atomLink{
+			{Href: f.Link},
+			{Href: self, Rel: "self"},
+		},
+		Updated: f.Updated.UTC().Format(time.RFC3339),
+		Author:  f.Title,
+	}
+	for _, e := range f.Entries {
+		out.Entries = append(out.Entries, atomEntry{
+			ID:      e.ID,
+			Title:   e.Title,
+			Link:    atomLink{Href: e.Link},
+			Summary: e.Summary,
+			Updated: e.Updated.UTC().Format(time.RFC3339),
+		})
+	}
+	return marshal(out)
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssItem struct {
+	GUID        rssGUID `xml:"guid"`
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description,omitempty"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssFeed struct {
+	XMLName       xml.Name  `xml:"rss"`
+	Version       string    `xml:"version,attr"`
+	Title         string    `xml:"channel>title"`
+	Link          string    `xml:"channel>link"`
+	Description   string    `xml:"channel>description"`
+	LastBuildDate string    `xml:"channel>lastBuildDate"`
+	Items         []rssItem `xml:"channel>item"`
+}
+
+// RSS renders the feed in the RSS 2.0 format.
+func (f Feed) RSS() ([]byte, error) {
+	out := rssFeed{
+		Version:       "2.0",
+		Title:         f.Title,
+		Link:          f.Link,
+		Description:   f.Title,
+		LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+	}
+	for _, e := range f.Entries {
+		out.Items = append(out.Items, rssItem{
+			GUID:        rssGUID{Value: e.ID},
+			Title:       e.Title,
+			Link:        e.Link,
+			Description: e.Summary,
+			PubDate:     e.Updated.UTC().Format(time.RFC1123Z),
+		})
+	}
+	return marshal(out)
+}
+
+func marshal(v interface{}) ([]byte, error) {
+	buffer, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), buffer...), nil
+}
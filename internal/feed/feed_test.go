@@ -0,0 +1,65 @@
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	updated := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	f := Feed{
+		ID:      "http://localhost:8080/",
+		Title:   "Statuspage",
+		Link:    "http://localhost:8080/",
+		Updated: updated,
+		Entries: []Entry{{
+			ID:      "urn:patrol:transition:1",
+			Title:   "API / API Status is unhealthy",
+			Link:    "http://localhost:8080/items/1",
+			Summary: "Process exited with status 1 <stderr>",
+			Updated: updated,
+		}},
+	}
+
+	atom, err := f.Atom("http://localhost:8080/feed.atom")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var parsedAtom struct {
+		Entries []struct {
+			Title   string `xml:"title"`
+			Updated string `xml:"updated"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(atom, &parsedAtom); err != nil {
+		t.Error(fmt.Errorf("Atom feed is not valid XML: %s\n%s", err, atom))
+		return
+	}
+	if len(parsedAtom.Entries) != 1 || parsedAtom.Entries[0].Updated != "2021-06-01T12:00:00Z" {
+		t.Error(fmt.Errorf("Unexpected atom entries: %#v", parsedAtom))
+		return
+	}
+
+	rss, err := f.RSS()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var parsedRSS struct {
+		Items []struct {
+			Description string `xml:"description"`
+			PubDate     string `xml:"pubDate"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal(rss, &parsedRSS); err != nil {
+		t.Error(fmt.Errorf("RSS feed is not valid XML: %s\n%s", err, rss))
+		return
+	}
+	if len(parsedRSS.Items) != 1 || !strings.HasSuffix(parsedRSS.Items[0].Description, "<stderr>") || parsedRSS.Items[0].PubDate != "Tue, 01 Jun 2021 12:00:00 +0000" {
+		t.Error(fmt.Errorf("Unexpected RSS items: %#v", parsedRSS))
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/karimsa/patrol/internal/logger"
@@ -27,6 +28,75 @@ type Item struct {
 	MetricUnit string
 	Status     string
 	Error      string
+
+	// Number of times the check was run to produce this result, including
+	// retries after failures
+	Attempts int `json:",omitempty"`
+
+	// Boolean checks only keep the latest result of each day, so the time
+	// spent in each status by the results that an item replaced is
+	// accumulated here.
+	TimeHealthy     time.Duration `json:",omitempty"`
+	TimeUnhealthy   time.Duration `json:",omitempty"`
+	TimeMaintenance time.Duration `json:",omitempty"`
+
+	// Most severe status of the results of each hour (in UTC) of the day
+	// of a boolean item, as the first letter of the status or '-' for
+	// hours without results.
+	Hours string `json:",omitempty"`
+}
+
+// Statuses of boolean items, from least to most severe.
+var hourStatuses = []string{"healthy", "recovered", "maintenance", "unhealthy"}
+
+func hourSeverity(status string) int {
+	for idx, s := range hourStatuses {
+		if s == status {
+			return idx + 1
+		}
+	}
+	return 0
+}
+
+// HourStatus returns the most severe status of the results of the given
+// hour (in UTC) of the day of the item, or an empty string if there were
+// none. Items that were written before hourly statuses were kept only
+// know the status of their latest result.
+func (item Item) HourStatus(hour int) string {
+	if len(item.Hours) != 24 {
+		if !item.CreatedAt.IsZero() && item.CreatedAt.UTC().Hour() == hour {
+			return item.Status
+		}
+		return ""
+	}
+	for _, status := range hourStatuses {
+		if item.Hours[hour] == status[0] {
+			return status
+		}
+	}
+	return ""
+}
+
+// Keeps the most severe status of each hour of the item that is replaced,
+// along with the status of the new item.
+func (item *Item) recordHour(replaced Item) {
+	hours := []byte(strings.Repeat("-", 24))
+	for hour := range hours {
+		if status := replaced.HourStatus(hour); status != "" {
+			hours[hour] = status[0]
+		}
+	}
+	hour := item.CreatedAt.UTC().Hour()
+	if hourSeverity(item.Status) > hourSeverity(replaced.HourStatus(hour)) {
+		hours[hour] = item.Status[0]
+	}
+	item.Hours = string(hours)
+}
+
+// Accumulated returns the total time covered by the results that were
+// replaced by this item.
+func (item Item) Accumulated() time.Duration {
+	return item.TimeHealthy + item.TimeUnhealthy + item.TimeMaintenance
 }
 
 func (item Item) String() string {
@@ -46,6 +116,8 @@ func (item Item) String() string {
 		fmt.Sprintf("\tMetric: %.2f %s,", item.Metric, item.MetricUnit),
 		fmt.Sprintf("\tStatus: %s,", item.Status),
 		fmt.Sprintf("\tError: '%s',", item.Error),
+		fmt.Sprintf("\tAttempts: %d,", item.Attempts),
+		fmt.Sprintf("\tAccumulated: %s healthy, %s unhealthy, %s maintenance,", item.TimeHealthy, item.TimeUnhealthy, item.TimeMaintenance),
 		fmt.Sprintf("}"),
 	}, "\n")
 }
@@ -130,6 +202,7 @@ func (o CompactOptions) String() string {
 }
 
 type File struct {
+	path           string
 	fd             *os.File
 	writes         chan *writeRequest
 	writerWg       *sync.WaitGroup
@@ -139,6 +212,8 @@ type File struct {
 	rwMux          *sync.RWMutex
 	maxEntries     int
 	compactOptions CompactOptions
+	compactErr     error
+	writerRunning  int32
 	logger         logger.Logger
 }
 
@@ -172,6 +247,7 @@ func New(options NewOptions) (*File, error) {
 	}
 
 	file := &File{
+		path:           options.File,
 		fd:             fd,
 		writes:         make(chan *writeRequest, options.MaxConcurrentWrites),
 		writerWg:       &sync.WaitGroup{},
@@ -205,6 +281,7 @@ func New(options NewOptions) (*File, error) {
 	}
 
 	file.writerWg.Add(1)
+	file.writerRunning = 1
 	go file.bgWriter()
 	return file, nil
 }
@@ -301,7 +378,9 @@ func (file *File) maybeCompact() {
 		file.logger.Debugf("Starting compaction: %s", file.compactOptions)
 		if _, err := file.doCompact(); err != nil {
 			file.logger.Warnf("Failed to compact file: %s", err)
+			file.compactErr = err
 		} else {
+			file.compactErr = nil
 			file.compactOptions.numWritesSinceCompact = 0
 			file.compactOptions.lastCompactTime = time.Now()
 		}
@@ -313,10 +392,35 @@ func (file *File) maybeCompact() {
 func (file *File) Compact() (numItems int, err error) {
 	file.rwMux.Lock()
 	n, err := file.doCompact()
+	file.compactErr = err
 	file.rwMux.Unlock()
 	return n, err
 }
 
+// CompactError returns the error of the latest compaction, or nil if it
+// succeeded.
+func (file *File) CompactError() error {
+	file.rwMux.RLock()
+	defer file.rwMux.RUnlock()
+	return file.compactErr
+}
+
+// WriterRunning returns true while the background writer is accepting new
+// items.
+func (file *File) WriterRunning() bool {
+	return atomic.LoadInt32(&file.writerRunning) == 1
+}
+
+// PendingWrites returns the number of items waiting to be written.
+func (file *File) PendingWrites() int {
+	return len(file.writes)
+}
+
+// Path returns the location of the history file on disk.
+func (file *File) Path() string {
+	return file.path
+}
+
 func (file *File) SetLogLevel(level logger.LogLevel) {
 	file.logger = logger.New(level, "history:")
 }
@@ -339,7 +443,10 @@ func (file *File) AddChecker(c checker) {
 
 func (file *File) bgWriter() {
 	var err error
-	defer file.writerWg.Done()
+	defer func() {
+		atomic.StoreInt32(&file.writerRunning, 0)
+		file.writerWg.Done()
+	}()
 
 	for {
 		select {
@@ -422,6 +529,27 @@ func (file *File) addItem(item Item, out io.Writer) (Item, error) {
 		item.Status = "recovered"
 	}
 
+	// Items read back from disk already contain their accumulated times
+	if item.Type == "boolean" && out != nil {
+		item.recordHour(node.value)
+	}
+	if item.Type == "boolean" && exists && out != nil {
+		item.TimeHealthy = lastValue.TimeHealthy
+		item.TimeUnhealthy = lastValue.TimeUnhealthy
+		item.TimeMaintenance = lastValue.TimeMaintenance
+
+		if elapsed := item.CreatedAt.Sub(lastValue.CreatedAt); elapsed > 0 {
+			switch lastValue.Status {
+			case "healthy", "recovered":
+				item.TimeHealthy += elapsed
+			case "unhealthy":
+				item.TimeUnhealthy += elapsed
+			case "maintenance":
+				item.TimeMaintenance += elapsed
+			}
+		}
+	}
+
 	// Write out first
 	if out != nil {
 		if err := item.writeTo(out); err != nil {
@@ -548,6 +676,21 @@ func (file *File) GetGroupItems(group, checkName string) []Item {
 	return list
 }
 
+// GetItem returns the item with the given ID, if it is still in history.
+func (file *File) GetItem(id string) (Item, bool) {
+	file.rwMux.RLock()
+	defer file.rwMux.RUnlock()
+
+	for _, group := range file.data {
+		for _, container := range group {
+			if node, ok := container.byID[id]; ok {
+				return node.value, true
+			}
+		}
+	}
+	return Item{}, false
+}
+
 func (file *File) Close() {
 	close(file.done)
 	file.writerWg.Wait()
@@ -142,6 +142,62 @@ func TestUpserts(t *testing.T) {
 	history.Close()
 }
 
+func TestAccumulatedTime(t *testing.T) {
+	os.Remove("./history-test-accumulate.db")
+	history, err := New(
+		NewOptions{
+			File: "./history-test-accumulate.db",
+		},
+	)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, status := range []string{"unhealthy", "healthy", "healthy"} {
+		if _, err := history.Append(Item{
+			Group:  "staging",
+			Name:   "Website is up",
+			Type:   "boolean",
+			Status: status,
+		}); err != nil {
+			t.Error(err)
+			return
+		}
+		<-time.After(10 * time.Millisecond)
+	}
+	history.Close()
+
+	var runAsserts = func() {
+		items := history.GetGroupItems("staging", "Website is up")
+		if len(items) != 1 {
+			t.Error(fmt.Errorf("Expected a single item, got: %#v", items))
+			return
+		}
+		if items[0].TimeUnhealthy < 10*time.Millisecond || items[0].TimeHealthy < 10*time.Millisecond || items[0].TimeMaintenance != 0 {
+			t.Error(fmt.Errorf("Time was not accumulated correctly: %s", items[0]))
+		}
+	}
+	runAsserts()
+
+	// Accumulated time should not change when the file is read back
+	history, err = New(
+		NewOptions{
+			File: "./history-test-accumulate.db",
+		},
+	)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	before := history.GetGroupItems("staging", "Website is up")
+	runAsserts()
+	if after := history.GetGroupItems("staging", "Website is up"); before[0].Accumulated() != after[0].Accumulated() {
+		t.Error(fmt.Errorf("Accumulated time changed after reading file: %s != %s", before[0], after[0]))
+	}
+	history.Close()
+}
+
 func TestAutoCompact(t *testing.T) {
 	dbFile := "./history-test-autocompact.db"
 	os.Remove(dbFile)
@@ -239,3 +295,29 @@ func TestAutoCompact(t *testing.T) {
 		return
 	}
 }
+
+func TestWriterState(t *testing.T) {
+	dbFile := "./history-test-state.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+	history, err := New(NewOptions{File: dbFile})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !history.WriterRunning() || history.CompactError() != nil {
+		t.Error(fmt.Errorf("Expected new history file to be writable"))
+		return
+	}
+
+	// Compacting a closed file fails, which should be remembered
+	history.fd.Close()
+	if _, err := history.Compact(); err == nil || history.CompactError() == nil {
+		t.Error(fmt.Errorf("Expected compaction of closed file to fail"))
+	}
+
+	history.Close()
+	if history.WriterRunning() {
+		t.Error(fmt.Errorf("Expected writer to stop once the history file is closed"))
+	}
+}
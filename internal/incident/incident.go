@@ -0,0 +1,256 @@
+package incident
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/karimsa/patrol/internal/history"
+)
+
+// Incident is a contiguous run of unhealthy results of a single check.
+type Incident struct {
+	ID    string
+	Group string
+	Check string
+
+	// Time of the first and last unhealthy results
+	Start, End time.Time
+
+	// Time of the first healthy result after the incident, zero value if
+	// the incident is ongoing
+	RecoveredAt time.Time
+
+	FirstError string
+	NumResults int
+}
+
+func (i Incident) String() string {
+	status := "ongoing"
+	if i.Resolved() {
+		status = fmt.Sprintf("recovered at %s", i.RecoveredAt.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("Incident{ID: %s, Group: %s, Check: %s, Start: %s, Duration: %s, %s, FirstError: '%s'}", i.ID, i.Group, i.Check, i.Start.Format(time.RFC3339), i.Duration().Round(time.Second), status, i.FirstError)
+}
+
+// Resolved returns true if the check has recovered from the incident.
+func (i Incident) Resolved() bool {
+	return !i.RecoveredAt.IsZero()
+}
+
+// Duration returns the time from the start of the incident until recovery,
+// or until now if the incident is ongoing.
+func (i Incident) Duration() time.Duration {
+	if i.Resolved() {
+		return i.RecoveredAt.Sub(i.Start)
+	}
+	return time.Since(i.Start)
+}
+
+func newIncident(item history.Item, start time.Time) Incident {
+	hash := sha1.Sum([]byte(fmt.Sprintf("%s|%s|%d", item.Group, item.Name, start.UnixNano())))
+	return Incident{
+		ID:         hex.EncodeToString(hash[:8]),
+		Group:      item.Group,
+		Check:      item.Name,
+		Start:      start,
+		End:        item.CreatedAt,
+		FirstError: item.Error,
+		NumResults: 1,
+	}
+}
+
+// Updates the open incident of a check with the next result, returning the
+// updated incident (if any) and whether it changed. When extracting incidents
+// from history, 'extract' should be true.
+func observe(open *Incident, item history.Item, extract bool) (*Incident, bool) {
+	switch item.Status {
+	case "unhealthy":
+		if open == nil || open.Resolved() {
+			i := newIncident(item, item.CreatedAt)
+			return &i, true
+		}
+		open.End = item.CreatedAt
+		open.NumResults++
+		return open, true
+
+	case "healthy", "recovered":
+		if open != nil && !open.Resolved() {
+			open.RecoveredAt = item.CreatedAt
+			return open, true
+		}
+
+		// Boolean items only keep the latest result of each day, so a
+		// recovered item might be the only trace left of an incident
+		if extract && item.Status == "recovered" && item.TimeUnhealthy > 0 {
+			i := newIncident(item, item.CreatedAt.Add(-item.Accumulated()))
+			i.End = item.CreatedAt
+			i.RecoveredAt = item.CreatedAt
+			i.FirstError = ""
+			return &i, true
+		}
+	}
+	return open, false
+}
+
+// Extract builds the list of incidents from the items of a single check,
+// ordered newest first (as returned by 'history.File').
+func Extract(items []history.Item) []Incident {
+	var incidents []Incident
+	var open *Incident
+	for idx := len(items) - 1; idx >= 0; idx-- {
+		next, changed := observe(open, items[idx], true)
+		if changed && next != open {
+			if open != nil {
+				incidents = append(incidents, *open)
+			}
+			open = next
+		}
+	}
+	if open != nil {
+		incidents = append(incidents, *open)
+	}
+
+	for i, j := 0, len(incidents)-1; i < j; i, j = i+1, j-1 {
+		incidents[i], incidents[j] = incidents[j], incidents[i]
+	}
+	return incidents
+}
+
+// Store keeps track of incidents as results come in, and persists them to a
+// JSON file.
+type Store struct {
+	path         string
+	maxIncidents int
+	rwMux        *sync.RWMutex
+	incidents    []Incident
+	open         map[string]*Incident
+}
+
+func checkKey(group, check string) string {
+	return group + "|" + check
+}
+
+// Open loads incidents from the given file. If the file does not exist yet,
+// incidents are extracted from the given history data instead, and the file is
+// only written once an incident changes. Only the latest 'maxIncidents'
+// incidents are kept.
+func Open(path string, maxIncidents int, data map[string]map[string][]history.Item) (*Store, error) {
+	store := &Store{
+		path:         path,
+		maxIncidents: maxIncidents,
+		rwMux:        &sync.RWMutex{},
+		open:         make(map[string]*Incident),
+	}
+
+	buffer, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		for _, group := range data {
+			for _, items := range group {
+				store.incidents = append(store.incidents, Extract(items)...)
+			}
+		}
+		store.sort()
+	} else if err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(buffer, &store.incidents); err != nil {
+		return nil, fmt.Errorf("Failed to parse %s: %s", path, err)
+	}
+
+	store.reindex()
+	return store, nil
+}
+
+// Sorts newest first and drops the oldest incidents past the limit. Must be
+// called with the write lock held.
+func (store *Store) sort() {
+	sort.SliceStable(store.incidents, func(i, j int) bool {
+		return store.incidents[i].Start.After(store.incidents[j].Start)
+	})
+	if store.maxIncidents > 0 && len(store.incidents) > store.maxIncidents {
+		store.incidents = store.incidents[:store.maxIncidents]
+	}
+}
+
+// Rebuilds the index of open incidents, since modifying the list invalidates
+// pointers into it. Must be called with the write lock held.
+func (store *Store) reindex() {
+	store.open = make(map[string]*Incident, len(store.open))
+	for idx := range store.incidents {
+		i := &store.incidents[idx]
+		if !i.Resolved() {
+			store.open[checkKey(i.Group, i.Check)] = i
+		}
+	}
+}
+
+// Must be called with the write lock held.
+func (store *Store) save() error {
+	buffer, err := json.Marshal(store.incidents)
+	if err != nil {
+		return err
+	}
+	tmpPath := store.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, buffer, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, store.path)
+}
+
+// Observe updates the incidents of a check with a new result. It returns the
+// incident that was opened, updated, or resolved by the result, if any.
+func (store *Store) Observe(item history.Item) (*Incident, error) {
+	store.rwMux.Lock()
+	defer store.rwMux.Unlock()
+
+	key := checkKey(item.Group, item.Name)
+	open := store.open[key]
+	next, changed := observe(open, item, false)
+	if !changed {
+		return nil, nil
+	}
+
+	updated := *next
+	if next != open {
+		store.incidents = append(store.incidents, *next)
+		store.sort()
+		store.reindex()
+	} else if next.Resolved() {
+		delete(store.open, key)
+	}
+	return &updated, store.save()
+}
+
+// List returns the incidents of the given group and check, newest first.
+// Empty values match all groups or checks.
+func (store *Store) List(group, check string) []Incident {
+	store.rwMux.RLock()
+	defer store.rwMux.RUnlock()
+
+	list := make([]Incident, 0, len(store.incidents))
+	for _, i := range store.incidents {
+		if (group == "" || i.Group == group) && (check == "" || i.Check == check) {
+			list = append(list, i)
+		}
+	}
+	return list
+}
+
+// Get returns the incident with the given ID.
+func (store *Store) Get(id string) (Incident, bool) {
+	store.rwMux.RLock()
+	defer store.rwMux.RUnlock()
+
+	for _, i := range store.incidents {
+		if i.ID == id {
+			return i, true
+		}
+	}
+	return Incident{}, false
+}
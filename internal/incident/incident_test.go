@@ -0,0 +1,116 @@
+package incident
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/karimsa/patrol/internal/history"
+)
+
+var start = time.Date(2021, 1, 10, 12, 0, 0, 0, time.UTC)
+
+func result(status string, minutes int) history.Item {
+	return history.Item{
+		Group:     "staging",
+		Name:      "Website is up",
+		Type:      "metric",
+		Status:    status,
+		Error:     fmt.Sprintf("error at %d", minutes),
+		CreatedAt: start.Add(time.Duration(minutes) * time.Minute),
+	}
+}
+
+func TestExtract(t *testing.T) {
+	// Newest first, as returned by history
+	incidents := Extract([]history.Item{
+		result("unhealthy", 6),
+		result("healthy", 5),
+		result("maintenance", 4),
+		result("unhealthy", 3),
+		result("unhealthy", 2),
+		result("healthy", 1),
+	})
+	if len(incidents) != 2 {
+		t.Error(fmt.Errorf("Expected 2 incidents, got: %#v", incidents))
+		return
+	}
+
+	ongoing, resolved := incidents[0], incidents[1]
+	if ongoing.Resolved() || !ongoing.Start.Equal(start.Add(6*time.Minute)) {
+		t.Error(fmt.Errorf("Wrong ongoing incident: %s", ongoing))
+	}
+	if !resolved.Resolved() || resolved.NumResults != 2 || resolved.FirstError != "error at 2" || resolved.Duration() != 3*time.Minute {
+		t.Error(fmt.Errorf("Wrong resolved incident: %s", resolved))
+	}
+}
+
+func TestExtractAccumulated(t *testing.T) {
+	incidents := Extract([]history.Item{
+		{
+			Group:         "staging",
+			Name:          "Website is up",
+			Type:          "boolean",
+			Status:        "recovered",
+			CreatedAt:     start,
+			TimeHealthy:   time.Hour,
+			TimeUnhealthy: time.Hour,
+		},
+	})
+	if len(incidents) != 1 || !incidents[0].Resolved() || incidents[0].Duration() != 2*time.Hour {
+		t.Error(fmt.Errorf("Wrong incidents extracted: %#v", incidents))
+	}
+}
+
+func TestStore(t *testing.T) {
+	os.Remove("incident-test.json")
+	defer os.Remove("incident-test.json")
+
+	store, err := Open("incident-test.json", 10, map[string]map[string][]history.Item{
+		"staging": {
+			"Website is up": {result("healthy", 2), result("unhealthy", 1)},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if list := store.List("", ""); len(list) != 1 {
+		t.Error(fmt.Errorf("Incidents were not extracted from history: %#v", list))
+		return
+	}
+	if _, err := os.Stat("incident-test.json"); !os.IsNotExist(err) {
+		t.Error(fmt.Errorf("Expected incidents to not be written until they change, got: %v", err))
+	}
+
+	for _, item := range []history.Item{result("unhealthy", 3), result("unhealthy", 4)} {
+		if _, err := store.Observe(item); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	// Ongoing incident should be picked up after reopening
+	store, err = Open("incident-test.json", 10, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	i, err := store.Observe(result("healthy", 5))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if i == nil || !i.Resolved() || i.NumResults != 2 {
+		t.Error(fmt.Errorf("Wrong incident resolved: %v", i))
+		return
+	}
+	if fetched, ok := store.Get(i.ID); !ok || !fetched.Resolved() {
+		t.Error(fmt.Errorf("Resolved incident was not stored: %v", fetched))
+		return
+	}
+	if list := store.List("staging", "Website is up"); len(list) != 2 {
+		t.Error(fmt.Errorf("Wrong number of incidents: %#v", list))
+	}
+}
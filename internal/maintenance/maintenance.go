@@ -0,0 +1,219 @@
+package maintenance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression in the standard five field format:
+// minute, hour, day of month, month, and day of week. Each field supports
+// '*', lists ('1,2'), ranges ('1-5'), and steps ('*/15'). Months and days
+// of the week can also be given by their three letter names ('JAN', 'SUN').
+type Schedule struct {
+	expr                              string
+	minute, hour, dom, month, weekday map[int]bool
+	anyDom, anyWeekday                bool
+}
+
+var fieldBounds = [5][2]int{
+	{0, 59},
+	{0, 23},
+	{1, 31},
+	{1, 12},
+	{0, 6},
+}
+
+var fieldNames = [5][]string{
+	4: {"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"},
+	3: {"", "JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"},
+}
+
+func parseValue(value string, names []string) (int, error) {
+	for n, name := range names {
+		if name != "" && strings.EqualFold(value, name) {
+			return n, nil
+		}
+	}
+	return strconv.Atoi(value)
+}
+
+func parseField(field string, min, max int, names []string) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx != -1 {
+			n, err := strconv.Atoi(part[idx+1:])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid step in '%s'", part)
+			}
+			step = n
+			part = part[:idx]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			n, err := parseValue(bounds[0], names)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value '%s'", part)
+			}
+			lo, hi = n, n
+			if len(bounds) == 2 {
+				if hi, err = parseValue(bounds[1], names); err != nil {
+					return nil, fmt.Errorf("invalid range '%s'", part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("'%s' is out of range [%d, %d]", part, min, max)
+		}
+		for i := lo; i <= hi; i += step {
+			values[i] = true
+		}
+	}
+	return values, nil
+}
+
+// ParseSchedule parses a five field cron expression.
+func ParseSchedule(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression '%s' must have 5 fields", expr)
+	}
+
+	var parsed [5]map[int]bool
+	for i, field := range fields {
+		values, err := parseField(field, fieldBounds[i][0], fieldBounds[i][1], fieldNames[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression '%s': %s", expr, err)
+		}
+		parsed[i] = values
+	}
+
+	return &Schedule{
+		expr:       expr,
+		minute:     parsed[0],
+		hour:       parsed[1],
+		dom:        parsed[2],
+		month:      parsed[3],
+		weekday:    parsed[4],
+		anyDom:     fields[2] == "*",
+		anyWeekday: fields[4] == "*",
+	}, nil
+}
+
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Matches returns true if the schedule fires at the minute containing 't'.
+func (s *Schedule) Matches(t time.Time) bool {
+	if !s.minute[t.Minute()] || !s.hour[t.Hour()] || !s.month[int(t.Month())] {
+		return false
+	}
+
+	return s.matchesDay(t)
+}
+
+func (s *Schedule) matchesDay(t time.Time) bool {
+	// As with cron, if both day fields are restricted then matching
+	// either one of them is enough
+	domMatches := s.dom[t.Day()]
+	weekdayMatches := s.weekday[int(t.Weekday())]
+	if !s.anyDom && !s.anyWeekday {
+		return domMatches || weekdayMatches
+	}
+	return domMatches && weekdayMatches
+}
+
+// Prev returns the latest minute at or before 't' at which the schedule
+// fires, unless it is before 'limit'. Months, days, and hours that do not
+// match are skipped as a whole.
+func (s *Schedule) Prev(t, limit time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute)
+	for !t.Before(limit) {
+		year, month, day := t.Date()
+		switch {
+		case !s.month[int(month)]:
+			t = time.Date(year, month, 1, 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+		case !s.matchesDay(t):
+			t = time.Date(year, month, day, 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+		case !s.hour[t.Hour()]:
+			t = time.Date(year, month, day, t.Hour(), 0, 0, 0, t.Location()).Add(-time.Minute)
+		case !s.minute[t.Minute()]:
+			t = t.Add(-time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Window is a period of time during which a check is expected to fail. A
+// window is either a one-off window between 'Start' and 'End', or a recurring
+// window that starts whenever 'Schedule' fires and lasts for 'Duration'.
+type Window struct {
+	Start, End time.Time
+	Schedule   *Schedule
+	Duration   time.Duration
+	Comment    string
+}
+
+func (w Window) String() string {
+	if w.Schedule != nil {
+		return fmt.Sprintf("Window{Schedule: '%s', Duration: %s}", w.Schedule, w.Duration)
+	}
+	return fmt.Sprintf("Window{Start: %s, End: %s}", w.Start, w.End)
+}
+
+// Validate verifies that the window is either a valid one-off or recurring
+// window.
+func (w Window) Validate() error {
+	if w.Schedule != nil {
+		if w.Duration <= 0 {
+			return fmt.Errorf("recurring maintenance window must have a positive duration")
+		}
+		if !w.Start.IsZero() || !w.End.IsZero() {
+			return fmt.Errorf("recurring maintenance window cannot have a start or end")
+		}
+		return nil
+	}
+	if w.Start.IsZero() || w.End.IsZero() {
+		return fmt.Errorf("maintenance window must have either a schedule or a start and end")
+	}
+	if !w.End.After(w.Start) {
+		return fmt.Errorf("maintenance window must end after it starts")
+	}
+	return nil
+}
+
+// Active returns the bounds of the occurrence of the window which contains
+// 't', if there is one.
+func (w Window) Active(t time.Time) (start, end time.Time, ok bool) {
+	if w.Schedule == nil {
+		return w.Start, w.End, !t.Before(w.Start) && t.Before(w.End)
+	}
+
+	// Of the occurrences that contain 't', the one that started last also
+	// ends last. Occurrences start on whole minutes, but can end at any time.
+	if start, ok := w.Schedule.Prev(t, t.Add(-w.Duration)); ok && t.Sub(start) < w.Duration {
+		return start, start.Add(w.Duration), true
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+type Windows []Window
+
+// Active returns the window which contains 't' with the latest end, if any.
+func (ws Windows) Active(t time.Time) (end time.Time, ok bool) {
+	for _, w := range ws {
+		if _, wEnd, wOk := w.Active(t); wOk && wEnd.After(end) {
+			end, ok = wEnd, true
+		}
+	}
+	return
+}
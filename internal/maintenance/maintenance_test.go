@@ -0,0 +1,112 @@
+package maintenance
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	for _, expr := range []string{"* * * * *", "*/15 2 * * 0", "0 1-5 1,15 * *", "30 4 * 1-12/2 1-5", "0 2 * JAN-MAR sun"} {
+		if _, err := ParseSchedule(expr); err != nil {
+			t.Error(fmt.Errorf("Failed to parse '%s': %s", expr, err))
+		}
+	}
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Error(fmt.Errorf("Expected '%s' to be rejected", expr))
+		}
+	}
+}
+
+func TestRecurringWindow(t *testing.T) {
+	schedule, err := ParseSchedule("0 2 * * 0")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	w := Window{Schedule: schedule, Duration: 2 * time.Hour}
+
+	// 2021-01-03 is a Sunday
+	for _, c := range []struct {
+		t      time.Time
+		active bool
+	}{
+		{time.Date(2021, 1, 3, 1, 59, 0, 0, time.Local), false},
+		{time.Date(2021, 1, 3, 2, 0, 0, 0, time.Local), true},
+		{time.Date(2021, 1, 3, 3, 59, 59, 0, time.Local), true},
+		{time.Date(2021, 1, 3, 4, 0, 0, 0, time.Local), false},
+		{time.Date(2021, 1, 4, 2, 30, 0, 0, time.Local), false},
+	} {
+		start, end, ok := w.Active(c.t)
+		if ok != c.active {
+			t.Error(fmt.Errorf("Expected active = %t at %s", c.active, c.t))
+		}
+		if ok && (!start.Equal(time.Date(2021, 1, 3, 2, 0, 0, 0, time.Local)) || !end.Equal(start.Add(2*time.Hour))) {
+			t.Error(fmt.Errorf("Wrong bounds for window at %s: %s - %s", c.t, start, end))
+		}
+	}
+
+	// Windows that do not last whole minutes end on time
+	w.Duration = 90 * time.Second
+	for _, c := range []struct {
+		t      time.Time
+		active bool
+	}{
+		{time.Date(2021, 1, 3, 2, 1, 29, 0, time.Local), true},
+		{time.Date(2021, 1, 3, 2, 1, 30, 0, time.Local), false},
+		{time.Date(2021, 1, 3, 2, 1, 59, 0, time.Local), false},
+	} {
+		if _, _, ok := w.Active(c.t); ok != c.active {
+			t.Error(fmt.Errorf("Expected 90s window to be active = %t at %s", c.active, c.t))
+		}
+	}
+}
+
+func TestSchedulePrev(t *testing.T) {
+	now := time.Date(2021, 3, 14, 12, 34, 0, 0, time.Local)
+	for _, expr := range []string{"* * * * *", "*/15 2 * * 0", "0 1-5 1,15 * *", "30 4 * 1-12/2 1-5", "0 2 * JAN-MAR sun"} {
+		schedule, err := ParseSchedule(expr)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected, ok := time.Time{}, false
+		for prev := now; now.Sub(prev) <= 60*24*time.Hour; prev = prev.Add(-time.Minute) {
+			if schedule.Matches(prev) {
+				expected, ok = prev, true
+				break
+			}
+		}
+		if prev, prevOk := schedule.Prev(now, now.Add(-60*24*time.Hour)); prevOk != ok || !prev.Equal(expected) {
+			t.Error(fmt.Errorf("Expected '%s' to have last fired at %s (%t), got: %s (%t)", expr, expected, ok, prev, prevOk))
+		}
+	}
+
+	// Long windows should not need to walk through every minute
+	schedule, err := ParseSchedule("0 0 1 JAN *")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	w := Window{Schedule: schedule, Duration: 365 * 24 * time.Hour}
+	if start, _, ok := w.Active(now); !ok || !start.Equal(time.Date(2021, 1, 1, 0, 0, 0, 0, time.Local)) {
+		t.Error(fmt.Errorf("Wrong start of yearly window: %s (%t)", start, ok))
+	}
+}
+
+func TestWindows(t *testing.T) {
+	now := time.Now()
+	ws := Windows{
+		{Start: now.Add(-time.Hour), End: now.Add(time.Hour)},
+		{Start: now.Add(-time.Minute), End: now.Add(2 * time.Hour)},
+		{Start: now.Add(time.Hour), End: now.Add(3 * time.Hour)},
+	}
+	if end, ok := ws.Active(now); !ok || !end.Equal(now.Add(2*time.Hour)) {
+		t.Error(fmt.Errorf("Wrong active window: %s, %t", end, ok))
+	}
+	if err := (Window{Start: now, End: now}).Validate(); err == nil {
+		t.Error(fmt.Errorf("Empty window should be invalid"))
+	}
+}
@@ -0,0 +1,218 @@
+package silence
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// Silence suppresses notifications for all checks that it matches until
+// it expires. Empty fields match everything, but at least one of them must
+// be set.
+type Silence struct {
+	ID        string
+	Group     string
+	Check     string
+	Labels    map[string]string
+	Comment   string
+	CreatedAt time.Time
+	EndsAt    time.Time
+}
+
+func (s Silence) String() string {
+	return fmt.Sprintf("Silence{ID: %s, Group: '%s', Check: '%s', Labels: %v, EndsAt: %s, Comment: '%s'}", s.ID, s.Group, s.Check, s.Labels, s.EndsAt, s.Comment)
+}
+
+// Matches returns true if the silence applies to the given check.
+func (s Silence) Matches(group, check string, labels map[string]string) bool {
+	if s.Group != "" && s.Group != group {
+		return false
+	}
+	if s.Check != "" && s.Check != check {
+		return false
+	}
+	for key, value := range s.Labels {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Silence) Expired(now time.Time) bool {
+	return !now.Before(s.EndsAt)
+}
+
+type acknowledgement struct {
+	Group     string
+	Check     string
+	CreatedAt time.Time
+}
+
+type storeData struct {
+	Silences         []Silence
+	Acknowledgements []acknowledgement
+}
+
+// Store keeps track of silences and acknowledgements, and persists them
+// to a JSON file so that they survive restarts.
+type Store struct {
+	path  string
+	rwMux *sync.RWMutex
+	data  storeData
+}
+
+// Open loads the store from the given file. A missing file is treated as
+// an empty store.
+func Open(path string) (*Store, error) {
+	store := &Store{
+		path:  path,
+		rwMux: &sync.RWMutex{},
+	}
+	buffer, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buffer, &store.data); err != nil {
+		return nil, fmt.Errorf("Failed to parse %s: %s", path, err)
+	}
+	return store, nil
+}
+
+// Must be called with the write lock held.
+func (store *Store) save() error {
+	now := time.Now()
+	active := make([]Silence, 0, len(store.data.Silences))
+	for _, s := range store.data.Silences {
+		if !s.Expired(now) {
+			active = append(active, s)
+		}
+	}
+	store.data.Silences = active
+
+	buffer, err := json.Marshal(store.data)
+	if err != nil {
+		return err
+	}
+	tmpPath := store.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, buffer, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, store.path)
+}
+
+// Add stores a new silence and returns it with its generated ID.
+func (store *Store) Add(s Silence) (Silence, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return s, err
+	}
+	s.ID = hex.EncodeToString(id)
+	s.CreatedAt = time.Now()
+	if s.Expired(s.CreatedAt) {
+		return s, fmt.Errorf("Silence must end in the future")
+	}
+	if s.Group == "" && s.Check == "" && len(s.Labels) == 0 {
+		return s, fmt.Errorf("Silence must match a group, check, or labels")
+	}
+
+	store.rwMux.Lock()
+	defer store.rwMux.Unlock()
+	store.data.Silences = append(store.data.Silences, s)
+	return s, store.save()
+}
+
+// Remove deletes the silence with the given ID. It returns false if no
+// such silence exists.
+func (store *Store) Remove(id string) (bool, error) {
+	store.rwMux.Lock()
+	defer store.rwMux.Unlock()
+
+	for idx, s := range store.data.Silences {
+		if s.ID == id {
+			store.data.Silences = append(store.data.Silences[:idx], store.data.Silences[idx+1:]...)
+			return true, store.save()
+		}
+	}
+	return false, nil
+}
+
+// List returns all silences that have not yet expired.
+func (store *Store) List() []Silence {
+	store.rwMux.RLock()
+	defer store.rwMux.RUnlock()
+
+	now := time.Now()
+	list := make([]Silence, 0, len(store.data.Silences))
+	for _, s := range store.data.Silences {
+		if !s.Expired(now) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// Find returns the active silence that matches the given check, if any.
+func (store *Store) Find(group, check string, labels map[string]string) (Silence, bool) {
+	for _, s := range store.List() {
+		if s.Matches(group, check, labels) {
+			return s, true
+		}
+	}
+	return Silence{}, false
+}
+
+// Acknowledge marks the current failure of a check as known.
+func (store *Store) Acknowledge(group, check string) error {
+	store.rwMux.Lock()
+	defer store.rwMux.Unlock()
+
+	for _, ack := range store.data.Acknowledgements {
+		if ack.Group == group && ack.Check == check {
+			return nil
+		}
+	}
+	store.data.Acknowledgements = append(store.data.Acknowledgements, acknowledgement{
+		Group:     group,
+		Check:     check,
+		CreatedAt: time.Now(),
+	})
+	return store.save()
+}
+
+// Unacknowledge clears the acknowledgement of a check. It returns false if
+// the check was not acknowledged.
+func (store *Store) Unacknowledge(group, check string) (bool, error) {
+	store.rwMux.Lock()
+	defer store.rwMux.Unlock()
+
+	for idx, ack := range store.data.Acknowledgements {
+		if ack.Group == group && ack.Check == check {
+			store.data.Acknowledgements = append(store.data.Acknowledgements[:idx], store.data.Acknowledgements[idx+1:]...)
+			return true, store.save()
+		}
+	}
+	return false, nil
+}
+
+// IsAcknowledged returns true if the current failure of a check was
+// acknowledged.
+func (store *Store) IsAcknowledged(group, check string) bool {
+	store.rwMux.RLock()
+	defer store.rwMux.RUnlock()
+
+	for _, ack := range store.data.Acknowledgements {
+		if ack.Group == group && ack.Check == check {
+			return true
+		}
+	}
+	return false
+}
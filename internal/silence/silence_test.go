@@ -0,0 +1,79 @@
+package silence
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMatches(t *testing.T) {
+	s := Silence{
+		Group:  "staging",
+		Labels: map[string]string{"team": "web"},
+	}
+	for _, c := range []struct {
+		group, check string
+		labels       map[string]string
+		matches      bool
+	}{
+		{"staging", "Website is up", map[string]string{"team": "web", "tier": "1"}, true},
+		{"staging", "Website is up", map[string]string{"team": "db"}, false},
+		{"staging", "Website is up", nil, false},
+		{"production", "Website is up", map[string]string{"team": "web"}, false},
+	} {
+		if s.Matches(c.group, c.check, c.labels) != c.matches {
+			t.Error(fmt.Errorf("Expected match = %t for %#v", c.matches, c))
+		}
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	os.Remove("silence-test.json")
+	defer os.Remove("silence-test.json")
+
+	store, err := Open("silence-test.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := store.Add(Silence{Group: "staging", EndsAt: time.Now().Add(-time.Minute)}); err == nil {
+		t.Error(fmt.Errorf("Expired silences should be rejected"))
+		return
+	}
+	if _, err := store.Add(Silence{Labels: map[string]string{}, EndsAt: time.Now().Add(time.Hour)}); err == nil {
+		t.Error(fmt.Errorf("Silences without any matchers should be rejected"))
+		return
+	}
+	s, err := store.Add(Silence{Group: "staging", EndsAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err := store.Acknowledge("production", "Website is up"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	store, err = Open("silence-test.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if found, ok := store.Find("staging", "Website is up", nil); !ok || found.ID != s.ID {
+		t.Error(fmt.Errorf("Silence was not persisted: %#v", store.List()))
+		return
+	}
+	if !store.IsAcknowledged("production", "Website is up") {
+		t.Error(fmt.Errorf("Acknowledgement was not persisted"))
+		return
+	}
+	if ok, err := store.Remove(s.ID); err != nil || !ok {
+		t.Error(fmt.Errorf("Failed to remove silence: %v", err))
+		return
+	}
+	if _, ok := store.Find("staging", "Website is up", nil); ok {
+		t.Error(fmt.Errorf("Silence was not removed"))
+		return
+	}
+}
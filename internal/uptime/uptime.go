@@ -0,0 +1,268 @@
+package uptime
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/karimsa/patrol/internal/history"
+)
+
+// Windows over which uptime is calculated when none are configured.
+var DefaultWindows = []time.Duration{
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+	30 * 24 * time.Hour,
+	90 * 24 * time.Hour,
+}
+
+// ParseWindow parses a duration that may also be specified in days ('7d').
+func ParseWindow(str string) (time.Duration, error) {
+	if strings.HasSuffix(str, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(str, "d"))
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("Invalid window: '%s'", str)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("Window must be positive: '%s'", str)
+	}
+	return d, nil
+}
+
+// FormatWindow formats a window in days if it is a whole number of days
+// longer than a single day.
+func FormatWindow(window time.Duration) string {
+	if window > 24*time.Hour && window%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", window/(24*time.Hour))
+	}
+	if window%time.Hour == 0 {
+		return fmt.Sprintf("%dh", window/time.Hour)
+	}
+	return window.String()
+}
+
+// FormatCovered formats how much of a window has data, rounded down to
+// whole days, hours, minutes, or seconds.
+func FormatCovered(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return fmt.Sprintf("%ds", d/time.Second)
+}
+
+// Result of an uptime calculation over a window. Time spent under maintenance
+// or for which there is no data is counted as neither up nor down.
+type Result struct {
+	Window   time.Duration
+	Up, Down time.Duration
+
+	// Time from the oldest data within the window until the end of the
+	// window. The history only keeps a limited number of results for each
+	// check, which might not reach back to the start of longer windows.
+	Covered time.Duration
+}
+
+func (r Result) String() string {
+	if !r.Known() {
+		return fmt.Sprintf("%s: no data", FormatWindow(r.Window))
+	}
+	if r.Partial() {
+		return fmt.Sprintf("%s: %.3f%% (only %s of data)", FormatWindow(r.Window), r.Percent(), FormatCovered(r.Covered))
+	}
+	return fmt.Sprintf("%s: %.3f%%", FormatWindow(r.Window), r.Percent())
+}
+
+// Known returns true if there was any data within the window.
+func (r Result) Known() bool {
+	return r.Up+r.Down > 0
+}
+
+// Partial returns true if the data does not reach back to the start of the
+// window, so the result only describes the end of it.
+func (r Result) Partial() bool {
+	return r.Known() && r.Covered < r.Window
+}
+
+// Percent returns the percentage of known time during which the check was up.
+func (r Result) Percent() float64 {
+	if !r.Known() {
+		return 100
+	}
+	return 100 * float64(r.Up) / float64(r.Up+r.Down)
+}
+
+// A span of time, of which 'up' was spent healthy and 'down' was spent
+// unhealthy. The rest of the span is excluded from the calculation.
+type segment struct {
+	start, end time.Time
+	up, down   time.Duration
+}
+
+func (s segment) length() time.Duration {
+	return s.end.Sub(s.start)
+}
+
+// Returns the segment scaled down to the portion between 'start' and 'end'.
+func (s segment) clip(start, end time.Time) segment {
+	if s.start.Before(start) {
+		s = s.scale(start, s.end)
+	}
+	if s.end.After(end) {
+		s = s.scale(s.start, end)
+	}
+	return s
+}
+
+func (s segment) scale(start, end time.Time) segment {
+	length := s.length()
+	clipped := segment{start: start, end: end}
+	if length > 0 && end.After(start) {
+		ratio := float64(end.Sub(start)) / float64(length)
+		clipped.up = time.Duration(float64(s.up) * ratio)
+		clipped.down = time.Duration(float64(s.down) * ratio)
+	}
+	return clipped
+}
+
+func statusSegment(status string, start, end time.Time) segment {
+	s := segment{start: start, end: end}
+	switch status {
+	case "healthy", "recovered":
+		s.up = s.length()
+	case "unhealthy":
+		s.down = s.length()
+	}
+	return s
+}
+
+// Converts the items of a single check (newest first) into segments (oldest
+// first). Each item covers the time accumulated by the results it replaced,
+// followed by the time until the next result.
+func segments(items []history.Item, now time.Time) []segment {
+	list := make([]segment, 0, 2*len(items))
+	end := now
+	for _, item := range items {
+		if item.CreatedAt.After(end) {
+			continue
+		}
+		list = append(list, statusSegment(item.Status, item.CreatedAt, end))
+		end = item.CreatedAt.Add(-item.Accumulated())
+		if item.Accumulated() > 0 {
+			list = append(list, segment{
+				start: end,
+				end:   item.CreatedAt,
+				up:    item.TimeHealthy,
+				down:  item.TimeUnhealthy,
+			})
+		}
+	}
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+	return list
+}
+
+// Returns the time from the start of the oldest segment (or of the window,
+// if it is later) until 'now'.
+func covered(list []segment, start, now time.Time) time.Duration {
+	if len(list) == 0 {
+		return 0
+	}
+	if list[0].start.After(start) {
+		start = list[0].start
+	}
+	return now.Sub(start)
+}
+
+// ForCheck calculates the uptime of a single check over the given window,
+// from items ordered newest first (as returned by 'history.File').
+func ForCheck(items []history.Item, window time.Duration, now time.Time) Result {
+	result := Result{Window: window}
+	start := now.Add(-window)
+	list := segments(items, now)
+	result.Covered = covered(list, start, now)
+	for _, s := range list {
+		if s.end.After(start) {
+			s = s.clip(start, now)
+			result.Up += s.up
+			result.Down += s.down
+		}
+	}
+	return result
+}
+
+// ForService calculates the uptime of a service over the given window. A
+// service is considered up only while all of its checks are healthy. Where
+// the exact timing of failures is not known (such as for results accumulated
+// into a single boolean item), checks are assumed to fail independently. The
+// window is only covered as far back as every check that has data reaches.
+func ForService(checks map[string][]history.Item, window time.Duration, now time.Time) Result {
+	result := Result{Window: window}
+	start := now.Add(-window)
+
+	all := make([][]segment, 0, len(checks))
+	boundaries := []time.Time{start, now}
+	hasData := false
+	for _, items := range checks {
+		list := segments(items, now)
+		if c := covered(list, start, now); len(list) > 0 && (!hasData || c < result.Covered) {
+			result.Covered = c
+			hasData = true
+		}
+		all = append(all, list)
+		for _, s := range list {
+			if s.start.After(start) {
+				boundaries = append(boundaries, s.start)
+			}
+			if s.end.After(start) {
+				boundaries = append(boundaries, s.end)
+			}
+		}
+	}
+	sort.Slice(boundaries, func(i, j int) bool {
+		return boundaries[i].Before(boundaries[j])
+	})
+
+	for i := 1; i < len(boundaries); i++ {
+		from, to := boundaries[i-1], boundaries[i]
+		length := to.Sub(from)
+		if length <= 0 {
+			continue
+		}
+
+		// Probability that no check is down, and that no check has data
+		pNotDown, pExcluded := 1.0, 1.0
+		for _, list := range all {
+			for _, s := range list {
+				if !s.start.After(from) && !s.end.Before(to) {
+					s = s.clip(from, to)
+					pNotDown *= 1 - float64(s.down)/float64(length)
+					pExcluded *= 1 - float64(s.up+s.down)/float64(length)
+					break
+				}
+			}
+		}
+
+		down := time.Duration((1 - pNotDown) * float64(length))
+		up := length - down - time.Duration(pExcluded*float64(length))
+		if up < 0 {
+			up = 0
+		}
+		result.Up += up
+		result.Down += down
+	}
+	return result
+}
@@ -0,0 +1,129 @@
+package uptime
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/karimsa/patrol/internal/history"
+)
+
+var now = time.Date(2021, 1, 10, 12, 0, 0, 0, time.UTC)
+
+func item(status string, ago time.Duration) history.Item {
+	return history.Item{
+		Type:      "metric",
+		Status:    status,
+		CreatedAt: now.Add(-ago),
+	}
+}
+
+func expectPercent(t *testing.T, r Result, percent float64) {
+	if !r.Known() || math.Abs(r.Percent()-percent) > 0.001 {
+		t.Error(fmt.Errorf("Expected %.3f%%, got: %s (up = %s, down = %s)", percent, r, r.Up, r.Down))
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	for str, expected := range map[string]time.Duration{
+		"24h": 24 * time.Hour,
+		"7d":  7 * 24 * time.Hour,
+		"90m": 90 * time.Minute,
+	} {
+		if window, err := ParseWindow(str); err != nil || window != expected {
+			t.Error(fmt.Errorf("Failed to parse '%s': %s, %v", str, window, err))
+		}
+	}
+	for _, str := range []string{"", "d", "-1d", "0s"} {
+		if _, err := ParseWindow(str); err == nil {
+			t.Error(fmt.Errorf("Expected '%s' to be rejected", str))
+		}
+	}
+	if str := FormatWindow(30 * 24 * time.Hour); str != "30d" {
+		t.Error(fmt.Errorf("Wrong format for 30 days: %s", str))
+	}
+}
+
+func TestForCheck(t *testing.T) {
+	items := []history.Item{
+		item("healthy", 1*time.Hour),
+		item("unhealthy", 2*time.Hour),
+		item("maintenance", 3*time.Hour),
+		item("healthy", 5*time.Hour),
+	}
+
+	// 1h unhealthy, 3h healthy, 1h maintenance
+	expectPercent(t, ForCheck(items, 6*time.Hour, now), 75)
+	// Window cuts the oldest item in half
+	expectPercent(t, ForCheck(items, 4*time.Hour, now), 2.0/3*100)
+
+	if r := ForCheck(items, 30*time.Minute, now.Add(-6*time.Hour)); r.Known() {
+		t.Error(fmt.Errorf("Expected no data before first item: %s", r))
+	}
+}
+
+func TestAccumulatedItems(t *testing.T) {
+	items := []history.Item{
+		{
+			Type:          "boolean",
+			Status:        "healthy",
+			CreatedAt:     now.Add(-1 * time.Hour),
+			TimeHealthy:   3 * time.Hour,
+			TimeUnhealthy: 1 * time.Hour,
+		},
+	}
+
+	// 4h accumulated (75% up) followed by 1h healthy
+	expectPercent(t, ForCheck(items, 24*time.Hour, now), 80)
+	// Half of the accumulated time is within the window
+	expectPercent(t, ForCheck(items, 3*time.Hour, now), 2.5/3*100)
+}
+
+func TestForService(t *testing.T) {
+	checks := map[string][]history.Item{
+		"a": {
+			item("healthy", 1*time.Hour),
+			item("unhealthy", 2*time.Hour),
+			item("healthy", 4*time.Hour),
+		},
+		"b": {
+			item("unhealthy", 1*time.Hour),
+			item("healthy", 3*time.Hour),
+		},
+	}
+
+	// Down while either check is down: 2h to 1h ago (a), and last 1h (b)
+	expectPercent(t, ForService(checks, 4*time.Hour, now), 50)
+}
+
+func TestPartialWindow(t *testing.T) {
+	// Only the latest results are kept, which do not reach back 30 days
+	items := []history.Item{
+		item("healthy", 1*time.Hour),
+		item("unhealthy", 2*time.Hour),
+	}
+	window := 30 * 24 * time.Hour
+
+	r := ForCheck(items, window, now)
+	expectPercent(t, r, 50)
+	if !r.Partial() || r.Covered != 2*time.Hour {
+		t.Error(fmt.Errorf("Expected 2h of 30d covered: %s (covered = %s)", r, r.Covered))
+	}
+	if str := r.String(); str != "30d: 50.000% (only 2h of data)" {
+		t.Error(fmt.Errorf("Wrong format for partial result: %s", str))
+	}
+	if r := ForCheck(items, time.Hour, now); r.Partial() || r.Covered != time.Hour {
+		t.Error(fmt.Errorf("Expected 1h to be fully covered: %s (covered = %s)", r, r.Covered))
+	}
+
+	// The service is only covered as far back as its shortest history
+	r = ForService(map[string][]history.Item{
+		"a":     items,
+		"b":     {item("healthy", 3*time.Hour), item("healthy", 4*time.Hour)},
+		"empty": nil,
+	}, window, now)
+	if !r.Partial() || r.Covered != 2*time.Hour {
+		t.Error(fmt.Errorf("Expected 2h of 30d covered for service: %s (covered = %s)", r, r.Covered))
+	}
+}
@@ -0,0 +1,330 @@
+package patrol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Text that interpolated values are replaced with when the config is
+// logged.
+const maskedValue = "******"
+
+var (
+	interpolationPattern = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+	envNamePattern       = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Returns the value that a reference, such as 'VAR', 'VAR:-default', or
+// 'file:/run/secrets/token', is replaced with, and whether it is a secret
+// (read from the environment or a file, rather than a fallback default).
+func resolveReference(ref string) (string, bool, error) {
+	if strings.HasPrefix(ref, "file:") {
+		path := strings.TrimPrefix(ref, "file:")
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("Failed to read secret file: %s", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	}
+
+	name, fallback, hasFallback := ref, "", false
+	if idx := strings.Index(ref, ":-"); idx != -1 {
+		name, fallback, hasFallback = ref[:idx], ref[idx+2:], true
+	}
+	if !envNamePattern.MatchString(name) {
+		return "", false, fmt.Errorf("Invalid reference '${%s}' (use '$${' for a literal '${')", ref)
+	}
+	if value := os.Getenv(name); value != "" {
+		return value, true, nil
+	}
+	if hasFallback {
+		return fallback, false, nil
+	}
+	if _, ok := os.LookupEnv(name); ok {
+		return "", false, nil
+	}
+	return "", false, fmt.Errorf("Environment variable %s is not set (use '${%s:-}' to allow it to be empty)", name, name)
+}
+
+// Replaces references to environment variables and secret files in the
+// config before it is parsed, line by line so that errors keep pointing at
+// the right line. Values are escaped for the scalar that they are inserted
+// into, so that they cannot change the structure of the document. Comments
+// are left untouched, and '$${' is kept as a literal '${'.
+// Returns the interpolated config and the secret values that were inserted
+// into it.
+func interpolate(data []byte) ([]byte, []string, error) {
+	in := interpolator{blockIndent: -1}
+	lines := strings.Split(string(data), "\n")
+	for idx, line := range lines {
+		var err error
+		if lines[idx], err = in.line(line); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %s", idx+1, err)
+		}
+	}
+	return []byte(strings.Join(lines, "\n")), in.secrets, nil
+}
+
+// Scans a config line by line, keeping track of the kind of scalar that a
+// reference is in.
+type interpolator struct {
+	secrets []string
+
+	// Quote of a scalar that continues on the next line
+	quote byte
+	// Indentation of the line that started a block scalar, or -1
+	blockIndent int
+	// Depth of flow sequences and mappings
+	flow int
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+func isFlowIndicator(c byte) bool {
+	return strings.IndexByte(",[]{}", c) != -1
+}
+
+// Returns true if the line starts with a document marker ('---' or '...').
+func isDocumentMarker(line string) bool {
+	return (strings.HasPrefix(line, "---") || strings.HasPrefix(line, "...")) && (len(line) == 3 || isBlank(line[3]))
+}
+
+// Returns the index of the quote that ends a quoted scalar starting at
+// start, or the length of the line if it continues on the next line.
+func quotedEnd(line string, start int, quote byte) int {
+	for idx := start; idx < len(line); idx++ {
+		switch {
+		case quote == '"' && line[idx] == '\\':
+			idx++
+		case line[idx] == quote && quote == '\'' && idx+1 < len(line) && line[idx+1] == '\'':
+			idx++
+		case line[idx] == quote:
+			return idx
+		}
+	}
+	return len(line)
+}
+
+// Returns the index that a plain (unquoted) scalar starting at start ends
+// at, skipping over references.
+func (in *interpolator) plainEnd(line string, start int) int {
+	end := start
+	for end < len(line) {
+		c := line[end]
+		if c == '$' {
+			if match := interpolationPattern.FindStringIndex(line[end:]); match != nil && match[0] == 0 {
+				end += match[1]
+				continue
+			}
+		}
+		if c == ':' && (end+1 == len(line) || isBlank(line[end+1]) || (in.flow > 0 && isFlowIndicator(line[end+1]))) {
+			break
+		}
+		if c == '#' && end > start && isBlank(line[end-1]) {
+			break
+		}
+		if in.flow > 0 && isFlowIndicator(c) {
+			break
+		}
+		end++
+	}
+	for end > start && isBlank(line[end-1]) {
+		end--
+	}
+	return end
+}
+
+// Interpolates a single line of the config.
+func (in *interpolator) line(line string) (string, error) {
+	var out strings.Builder
+	idx := 0
+	if isDocumentMarker(line) {
+		// Ends anything that was left open by the previous document
+		in.quote, in.blockIndent, in.flow = 0, -1, 0
+		out.WriteString(line[:3])
+		idx = 3
+	}
+
+	indent := len(line) - len(strings.TrimLeft(line, " \t"))
+	if in.blockIndent != -1 {
+		if strings.TrimSpace(line) == "" || indent > in.blockIndent {
+			// Block scalars are taken literally
+			value, _, err := in.replace(line, func(value string) string { return value })
+			return value, err
+		}
+		in.blockIndent = -1
+	}
+
+	if in.quote != 0 {
+		idx = quotedEnd(line, 0, in.quote)
+		if err := in.writeQuoted(&out, line[:idx], in.quote); err != nil {
+			return "", err
+		}
+		if idx == len(line) {
+			return out.String(), nil
+		}
+		out.WriteByte(in.quote)
+		in.quote = 0
+		idx++
+	}
+
+	for idx < len(line) {
+		c := line[idx]
+		switch {
+		case isBlank(c):
+			out.WriteByte(c)
+			idx++
+		case c == '#' && (idx == 0 || isBlank(line[idx-1])):
+			out.WriteString(line[idx:])
+			return out.String(), nil
+		case c == '\'' || c == '"':
+			end := quotedEnd(line, idx+1, c)
+			out.WriteByte(c)
+			if err := in.writeQuoted(&out, line[idx+1:end], c); err != nil {
+				return "", err
+			}
+			if end == len(line) {
+				in.quote = c
+				return out.String(), nil
+			}
+			out.WriteByte(c)
+			idx = end + 1
+		case c == '[' || c == '{':
+			in.flow++
+			out.WriteByte(c)
+			idx++
+		case c == ']' || c == '}':
+			if in.flow > 0 {
+				in.flow--
+			}
+			out.WriteByte(c)
+			idx++
+		case c == ',' && in.flow > 0:
+			out.WriteByte(c)
+			idx++
+		case (c == '-' || c == '?' || c == ':') && (idx+1 == len(line) || isBlank(line[idx+1])):
+			out.WriteByte(c)
+			idx++
+		case (c == '|' || c == '>') && in.flow == 0:
+			in.blockIndent = indent
+			out.WriteString(line[idx:])
+			return out.String(), nil
+		case c == '&' || c == '*' || c == '!':
+			end := idx
+			for end < len(line) && !isBlank(line[end]) && !(in.flow > 0 && isFlowIndicator(line[end])) {
+				end++
+			}
+			out.WriteString(line[idx:end])
+			idx = end
+		default:
+			end := in.plainEnd(line, idx)
+			value, plain, err := in.replace(line[idx:end], func(value string) string { return value })
+			if err != nil {
+				return "", err
+			}
+			if !plain {
+				value = `"` + escapeDoubleQuoted(value) + `"`
+			}
+			out.WriteString(value)
+			idx = end
+		}
+	}
+	return out.String(), nil
+}
+
+// Writes the interpolated contents of a quoted scalar, without its quotes.
+func (in *interpolator) writeQuoted(out *strings.Builder, text string, quote byte) error {
+	escape := escapeDoubleQuoted
+	if quote == '\'' {
+		escape = func(value string) string { return strings.ReplaceAll(value, "'", "''") }
+	}
+	value, _, err := in.replace(text, escape)
+	out.WriteString(value)
+	return err
+}
+
+// Characters that values can contain and still be inserted into a plain
+// scalar as they are, such as numbers, durations, and tokens.
+var plainValuePattern = regexp.MustCompile(`^([A-Za-z0-9_./+][A-Za-z0-9_./+=-]*)?$`)
+
+// Replaces the references in text with their escaped values. Returns
+// whether all of the values can be used in a plain scalar as they are.
+func (in *interpolator) replace(text string, escape func(string) string) (string, bool, error) {
+	var err error
+	plain := true
+	text = interpolationPattern.ReplaceAllStringFunc(text, func(match string) string {
+		if err != nil {
+			return match
+		}
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+		value, secret, resolveErr := resolveReference(match[2 : len(match)-1])
+		if resolveErr != nil {
+			err = resolveErr
+			return match
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			err = fmt.Errorf("Value of '%s' must be a single line", match)
+			return match
+		}
+		if secret && value != "" {
+			in.secrets = append(in.secrets, value)
+		}
+		plain = plain && plainValuePattern.MatchString(value) && !strings.EqualFold(value, "null")
+		return escape(value)
+	})
+	return text, plain, err
+}
+
+// Escapes a value for a double-quoted YAML scalar, whose escape sequences
+// are a superset of JSON's.
+func escapeDoubleQuoted(value string) string {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	quoted := strings.TrimSpace(buffer.String())
+	return quoted[1 : len(quoted)-1]
+}
+
+// Replaces the given values wherever they appear in the strings of a JSON
+// document.
+func maskJSON(data []byte, secrets []string) ([]byte, error) {
+	if len(secrets) == 0 {
+		return data, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(maskValue(doc, secrets), "", "\t")
+}
+
+func maskValue(value interface{}, secrets []string) interface{} {
+	switch v := value.(type) {
+	case string:
+		for _, secret := range secrets {
+			v = strings.ReplaceAll(v, secret, maskedValue)
+		}
+		return v
+	case []interface{}:
+		for idx := range v {
+			v[idx] = maskValue(v[idx], secrets)
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = maskValue(v[key], secrets)
+		}
+	}
+	return value
+}
@@ -0,0 +1,142 @@
+package patrol
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestInterpolateScalars(t *testing.T) {
+	// Values that would change the structure of the document if they were
+	// inserted as they are
+	unsafe := `*a: "b" #c, [d] {e} 'f' \g`
+	os.Setenv("PATROL_TEST_UNSAFE", unsafe)
+	os.Setenv("PATROL_TEST_PORT", "8081")
+	os.Unsetenv("PATROL_TEST_MISSING")
+	defer os.Unsetenv("PATROL_TEST_UNSAFE")
+	defer os.Unsetenv("PATROL_TEST_PORT")
+
+	for _, test := range []struct {
+		name     string
+		config   string
+		expected interface{}
+	}{
+		{
+			name:     "plain",
+			config:   "key: ${PATROL_TEST_UNSAFE}\nport: ${PATROL_TEST_PORT}\n",
+			expected: map[interface{}]interface{}{"key": unsafe, "port": 8081},
+		},
+		{
+			name:     "plain with text around it",
+			config:   "key: x ${PATROL_TEST_UNSAFE} y # ${PATROL_TEST_MISSING}\n",
+			expected: map[interface{}]interface{}{"key": "x " + unsafe + " y"},
+		},
+		{
+			name:     "plain key",
+			config:   "${PATROL_TEST_UNSAFE}: ${PATROL_TEST_PORT}\n",
+			expected: map[interface{}]interface{}{unsafe: 8081},
+		},
+		{
+			name:     "tagged",
+			config:   "port: !!str ${PATROL_TEST_PORT}\n",
+			expected: map[interface{}]interface{}{"port": "8081"},
+		},
+		{
+			name:     "single-quoted",
+			config:   "key: 'x ${PATROL_TEST_UNSAFE} $${HOME}'\n",
+			expected: map[interface{}]interface{}{"key": "x " + unsafe + " ${HOME}"},
+		},
+		{
+			name:     "double-quoted",
+			config:   "key: \"x ${PATROL_TEST_UNSAFE} $${HOME}\"\n",
+			expected: map[interface{}]interface{}{"key": "x " + unsafe + " ${HOME}"},
+		},
+		{
+			name:     "multi-line single-quoted",
+			config:   "key: 'x\n  ${PATROL_TEST_UNSAFE}\n  y'\nport: ${PATROL_TEST_PORT}\n",
+			expected: map[interface{}]interface{}{"key": "x " + unsafe + " y", "port": 8081},
+		},
+		{
+			name:     "multi-line double-quoted",
+			config:   "key: \"x\n  ${PATROL_TEST_UNSAFE}\n  y\"\nport: ${PATROL_TEST_PORT}\n",
+			expected: map[interface{}]interface{}{"key": "x " + unsafe + " y", "port": 8081},
+		},
+		{
+			name:     "literal block",
+			config:   "key: |\n  ${PATROL_TEST_UNSAFE}\n\n  # ${PATROL_TEST_PORT}\nport: ${PATROL_TEST_PORT}\n",
+			expected: map[interface{}]interface{}{"key": unsafe + "\n\n# 8081\n", "port": 8081},
+		},
+		{
+			name:     "folded block with indentation indicator",
+			config:   "key: >2-\n    ${PATROL_TEST_UNSAFE}\n    y\nport: ${PATROL_TEST_PORT}\n",
+			expected: map[interface{}]interface{}{"key": "  " + unsafe + "\n  y", "port": 8081},
+		},
+		{
+			name:     "block in sequence",
+			config:   "list:\n- |\n  ${PATROL_TEST_UNSAFE}\n- ${PATROL_TEST_UNSAFE}\n",
+			expected: map[interface{}]interface{}{"list": []interface{}{unsafe + "\n", unsafe}},
+		},
+		{
+			name:     "flow sequence",
+			config:   "list: [${PATROL_TEST_UNSAFE}, ${PATROL_TEST_PORT}, '${PATROL_TEST_UNSAFE}']\n",
+			expected: map[interface{}]interface{}{"list": []interface{}{unsafe, 8081, unsafe}},
+		},
+		{
+			name:     "multi-line flow sequence",
+			config:   "list: [\n  ${PATROL_TEST_UNSAFE},\n  ${PATROL_TEST_PORT}\n]\nport: ${PATROL_TEST_PORT}\n",
+			expected: map[interface{}]interface{}{"list": []interface{}{unsafe, 8081}, "port": 8081},
+		},
+		{
+			name:     "multi-line flow mapping",
+			config:   "map: {\n  a: ${PATROL_TEST_UNSAFE},\n  ${PATROL_TEST_UNSAFE}: ${PATROL_TEST_PORT} }\n",
+			expected: map[interface{}]interface{}{"map": map[interface{}]interface{}{"a": unsafe, unsafe: 8081}},
+		},
+		{
+			name:     "anchors in flow",
+			config:   "list: [&a ${PATROL_TEST_UNSAFE}, *a, !!str ${PATROL_TEST_PORT}]\n",
+			expected: map[interface{}]interface{}{"list": []interface{}{unsafe, unsafe, "8081"}},
+		},
+		{
+			name:     "anchors in block",
+			config:   "a: &value ${PATROL_TEST_UNSAFE}\nb: *value\n",
+			expected: map[interface{}]interface{}{"a": unsafe, "b": unsafe},
+		},
+		{
+			name:     "complex key",
+			config:   "? ${PATROL_TEST_UNSAFE}\n: ${PATROL_TEST_PORT}\n",
+			expected: map[interface{}]interface{}{unsafe: 8081},
+		},
+		{
+			name:     "document markers",
+			config:   "---\nkey: ${PATROL_TEST_UNSAFE}\n...\n",
+			expected: map[interface{}]interface{}{"key": unsafe},
+		},
+		{
+			name:     "scalar document",
+			config:   "--- ${PATROL_TEST_UNSAFE}\n",
+			expected: unsafe,
+		},
+		{
+			name:     "block document",
+			config:   "--- |\n  ${PATROL_TEST_UNSAFE}\n",
+			expected: unsafe + "\n",
+		},
+	} {
+		data, _, err := interpolate([]byte(test.config))
+		if err != nil {
+			t.Error(fmt.Errorf("%s: %s", test.name, err))
+			continue
+		}
+		var value interface{}
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			t.Error(fmt.Errorf("%s: failed to parse %q: %s", test.name, data, err))
+			continue
+		}
+		if !reflect.DeepEqual(value, test.expected) {
+			t.Error(fmt.Errorf("%s: expected %#v, got %#v from %q", test.name, test.expected, value, data))
+		}
+	}
+}
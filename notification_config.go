@@ -117,11 +117,7 @@ func (sn *singleNotificationConfig) Run() {
 
 	if notifier == nil {
 		logger.Warnf("Could not send notification using empty notifier")
-	} else {
-		go func() {
-			if err := notifier.exec(); err != nil {
-				logger.Warnf("Failed to send notification: %s", err)
-			}
-		}()
+	} else if err := notifier.exec(); err != nil {
+		logger.Warnf("Failed to send notification: %s", err)
 	}
 }
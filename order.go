@@ -0,0 +1,130 @@
+package patrol
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/karimsa/patrol/internal/history"
+)
+
+// Items of a single check, newest first.
+type CheckData struct {
+	Name  string
+	Items []history.Item
+}
+
+// Checks of a single group (service), in the order that they are shown.
+type GroupData struct {
+	Name   string
+	Checks []CheckData
+}
+
+// Returns the names in the order of the given ranks. Names without a rank
+// (such as services that were removed from the config but are still in the
+// history) follow, sorted by name.
+func sortByRank(names []string, rank map[string]int) []string {
+	sorted := append([]string(nil), names...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ri, iok := rank[sorted[i]]
+		rj, jok := rank[sorted[j]]
+		if iok != jok {
+			return iok
+		}
+		if iok {
+			return ri < rj
+		}
+		return sorted[i] < sorted[j]
+	})
+	return sorted
+}
+
+// SortGroups returns the names of groups in the order that they are
+// configured in, which is the order of the checkers.
+func (p *Patrol) SortGroups(names []string) []string {
+	rank := make(map[string]int)
+	for _, c := range p.checkers {
+		if _, ok := rank[c.Group]; !ok {
+			rank[c.Group] = len(rank)
+		}
+	}
+	return sortByRank(names, rank)
+}
+
+// SortChecks returns the names of checks of a group in the order that they
+// are configured in.
+func (p *Patrol) SortChecks(group string, names []string) []string {
+	rank := make(map[string]int)
+	for _, c := range p.checkers {
+		if c.Group == group {
+			rank[c.Name] = len(rank)
+		}
+	}
+	return sortByRank(names, rank)
+}
+
+// Arranges history data by group and check, in the configured order.
+func (p *Patrol) groupData(data map[string]map[string][]history.Item) []GroupData {
+	groupNames := make([]string, 0, len(data))
+	for groupName := range data {
+		groupNames = append(groupNames, groupName)
+	}
+
+	grouped := make([]GroupData, 0, len(data))
+	for _, groupName := range p.SortGroups(groupNames) {
+		checkNames := make([]string, 0, len(data[groupName]))
+		for checkName := range data[groupName] {
+			checkNames = append(checkNames, checkName)
+		}
+		group := GroupData{
+			Name:   groupName,
+			Checks: make([]CheckData, 0, len(checkNames)),
+		}
+		for _, checkName := range p.SortChecks(groupName, checkNames) {
+			group.Checks = append(group.Checks, CheckData{
+				Name:  checkName,
+				Items: data[groupName][checkName],
+			})
+		}
+		grouped = append(grouped, group)
+	}
+	return grouped
+}
+
+// GroupedData returns the items of every check, arranged by group and check
+// in the configured order.
+func (p *Patrol) GroupedData() []GroupData {
+	return p.groupData(p.History.GetData())
+}
+
+// JSON object whose keys are written in the order that they were added,
+// rather than sorted as they are for maps.
+type orderedObject []objectField
+
+type objectField struct {
+	Key   string
+	Value interface{}
+}
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for idx, field := range o {
+		if idx > 0 {
+			buffer.WriteByte(',')
+		}
+		key, err := json.Marshal(field.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.Value)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,125 @@
+package patrol
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/karimsa/patrol/internal/history"
+)
+
+func TestOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "patrol-order")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	removeTestDB("order-test.db")
+	defer removeTestDB("order-test.db")
+
+	for name, content := range map[string]string{
+		"patrol.yml": `
+db: order-test.db
+apiToken: secret
+include: [more.yml]
+services:
+  Zeta:
+    checks:
+    - name: Second
+      cmd: 'true'
+    - name: Third
+      cmd: 'true'
+    - name: First
+      order: -1
+      cmd: 'true'
+  Alpha:
+    order: 1
+    checks:
+    - name: Only
+      cmd: 'true'
+  Beta:
+    checks:
+    - name: Only
+      cmd: 'true'
+`,
+		"more.yml": `
+services:
+  Middle:
+    checks:
+    - name: Only
+      cmd: 'true'
+`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	p, raw, err := FromConfigFile(filepath.Join(dir, "patrol.yml"), nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer p.Close()
+
+	expected := []string{"Zeta|First", "Zeta|Second", "Zeta|Third", "Beta|Only", "Middle|Only", "Alpha|Only"}
+	var configured []string
+	for _, c := range p.checkers {
+		configured = append(configured, escalationKey(c.Group, c.Name))
+	}
+	if strings.Join(configured, ",") != strings.Join(expected, ",") {
+		t.Error(fmt.Errorf("Expected checkers in order %v, got: %v", expected, configured))
+	}
+	if names := raw.serviceNames(); strings.Join(names, ",") != "Zeta,Beta,Middle,Alpha" {
+		t.Error(fmt.Errorf("Expected services in configured order, got: %v", names))
+	}
+
+	// Services that are only in the history are shown last (to authenticated
+	// users)
+	for _, c := range append(p.checkers, nil) {
+		item := history.Item{Group: "Old", Name: "Removed", Type: "boolean", Status: "healthy"}
+		if c != nil {
+			item.Group, item.Name = c.Group, c.Name
+		}
+		if _, err := p.History.Append(item); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	expected = append(expected, "Old|Removed")
+
+	var grouped []string
+	for _, group := range p.GroupedData() {
+		for _, check := range group.Checks {
+			grouped = append(grouped, escalationKey(group.Name, check.Name))
+		}
+	}
+	if strings.Join(grouped, ",") != strings.Join(expected, ",") {
+		t.Error(fmt.Errorf("Expected grouped data in order %v, got: %v", expected, grouped))
+	}
+
+	assertOrder := func(path string, strs []string) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		res := httptest.NewRecorder()
+		p.ServeHTTP(res, req)
+		body := res.Body.String()
+		last := -1
+		for _, str := range strs {
+			idx := strings.Index(body, str)
+			if idx <= last {
+				t.Error(fmt.Errorf("Expected %s to contain %v in order, got: %s", path, strs, body))
+				return
+			}
+			last = idx
+		}
+	}
+	assertOrder("/", []string{">Zeta<", ">First<", ">Second<", ">Third<", ">Beta<", ">Middle<", ">Alpha<", ">Old<"})
+	assertOrder("/api/v1/uptime", []string{`"Zeta"`, `"First"`, `"Second"`, `"Third"`, `"Beta"`, `"Middle"`, `"Alpha"`, `"Old"`})
+}
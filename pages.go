@@ -0,0 +1,136 @@
+package patrol
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Page is an additional status page served by the same patrol instance,
+// showing a selection of its services.
+type Page struct {
+	// Name of the page, used in place of the name of the patrol instance.
+	Name string
+
+	// Path (under the base path) and hostname at which the page is served.
+	// At least one of them must be given, and requests must match both
+	// when both are given.
+	Path     string
+	Hostname string
+
+	// Services shown on the page. Zero value shows all services.
+	Services []string
+
+	// Visibility of the page: 'public' pages only ever show public checks
+	// (even to authenticated users), and 'internal' pages require
+	// authentication. Zero value follows the visibility of each check.
+	Visibility string
+}
+
+// Returns true if the page shows the given service.
+func (page *Page) hasService(group string) bool {
+	if len(page.Services) == 0 {
+		return true
+	}
+	for _, service := range page.Services {
+		if service == group {
+			return true
+		}
+	}
+	return false
+}
+
+// Validates and normalizes the given pages.
+func (p *Patrol) setPages(pages []Page) error {
+	seen := make(map[string]bool, len(pages))
+	for idx := range pages {
+		page := pages[idx]
+		var err error
+		if page.Path, err = normalizeBasePath(page.Path); err != nil {
+			return fmt.Errorf("Invalid path of page %d: %s", idx+1, err)
+		}
+		page.Hostname = strings.ToLower(page.Hostname)
+		if page.Path == "" && page.Hostname == "" {
+			return fmt.Errorf("Page %d must have a path other than '/' or a hostname", idx+1)
+		}
+		key := page.Hostname + page.Path
+		if seen[key] {
+			return fmt.Errorf("Page %d is served from the same location as another page: %s", idx+1, key)
+		}
+		seen[key] = true
+
+		if page.Visibility != "" && page.Visibility != "public" && page.Visibility != "internal" {
+			return fmt.Errorf("Invalid visibility of page %d: must be either 'public' or 'internal', got '%s'", idx+1, page.Visibility)
+		}
+		for _, service := range page.Services {
+			if !p.hasGroup(service) {
+				return fmt.Errorf("Page %d refers to unknown service: %s", idx+1, service)
+			}
+		}
+		if page.Name == "" {
+			page.Name = p.name
+		}
+		p.pages = append(p.pages, &page)
+	}
+	return nil
+}
+
+// Returns true if the request is for the given page.
+func (p *Patrol) matchesPage(req *http.Request, page *Page) bool {
+	if page.Hostname != "" {
+		_, host := p.requestOrigin(req)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if strings.ToLower(host) != page.Hostname {
+			return false
+		}
+	}
+	return page.Path == "" || req.URL.Path == page.Path || strings.HasPrefix(req.URL.Path, page.Path+"/")
+}
+
+type pageKey struct{}
+
+// Finds the page that the request is for, and returns the request with the
+// page stored in its context and the path of the page removed from its URL.
+// Responds with a redirect or error if the request cannot be routed.
+func (p *Patrol) routeRequest(res http.ResponseWriter, req *http.Request) (*http.Request, bool) {
+	req, ok := stripPathPrefix(res, req, "", p.basePath)
+	if !ok {
+		return req, false
+	}
+	for _, page := range p.pages {
+		if p.matchesPage(req, page) {
+			if req, ok = stripPathPrefix(res, req, p.basePath, page.Path); !ok {
+				return req, false
+			}
+			return req.WithContext(context.WithValue(req.Context(), pageKey{}, page)), true
+		}
+	}
+	return req, true
+}
+
+// Returns the page that the request is for, or nil for the main page.
+func pageOf(req *http.Request) *Page {
+	page, _ := req.Context().Value(pageKey{}).(*Page)
+	return page
+}
+
+// Returns the name of the page that the request is for.
+func (p *Patrol) pageName(req *http.Request) string {
+	if page := pageOf(req); page != nil {
+		return page.Name
+	}
+	return p.name
+}
+
+// Returns the path that links on the page that the request is for should
+// be prefixed with.
+func (p *Patrol) pagePath(req *http.Request) string {
+	if page := pageOf(req); page != nil {
+		return p.basePath + page.Path
+	}
+	return p.basePath
+}
@@ -0,0 +1,122 @@
+package patrol
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karimsa/patrol/internal/checker"
+	"github.com/karimsa/patrol/internal/history"
+)
+
+func TestPages(t *testing.T) {
+	removeTestDB("pages-test.db")
+	defer removeTestDB("pages-test.db")
+	historyFile, err := history.New(history.NewOptions{
+		File: "pages-test.db",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer historyFile.Close()
+
+	newChecker := func(group, name, visibility string) *checker.Checker {
+		return checker.New(&checker.Checker{
+			Group:      group,
+			Name:       name,
+			Cmd:        "true",
+			History:    historyFile,
+			Interval:   1 * time.Minute,
+			Visibility: visibility,
+		})
+	}
+	options := CreatePatrolOptions{
+		Name: "Everything",
+		Checkers: []*checker.Checker{
+			newChecker("Acme API", "Acme status", ""),
+			newChecker("Acme API", "Acme queue", "internal"),
+			newChecker("Globex Web", "Globex homepage", ""),
+		},
+		APIToken: "secret",
+	}
+
+	for _, pages := range [][]Page{
+		{{Name: "Root"}},
+		{{Path: "/acme"}, {Path: "acme/"}},
+		{{Path: "/acme", Services: []string{"Missing"}}},
+		{{Path: "/acme", Visibility: "secret"}},
+	} {
+		options.Pages = pages
+		if _, err := New(options, historyFile); err == nil {
+			t.Error(fmt.Errorf("Expected invalid pages to be rejected: %v", pages))
+		}
+	}
+
+	options.Pages = []Page{
+		{Name: "Acme Status", Path: "/acme", Services: []string{"Acme API"}, Visibility: "public"},
+		{Name: "Globex Status", Hostname: "status.globex.com", Services: []string{"Globex Web"}},
+		{Name: "Operations", Path: "/ops", Visibility: "internal"},
+	}
+	p, err := New(options, historyFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, c := range p.checkers {
+		if _, err := historyFile.Append(history.Item{Group: c.Group, Name: c.Name, Type: "boolean", Status: "healthy"}); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	request := func(host, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if host != "" {
+			req.Host = host
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res := httptest.NewRecorder()
+		p.ServeHTTP(res, req)
+		return res
+	}
+
+	for _, c := range []struct {
+		host, path, token string
+		status            int
+		shown, hidden     []string
+	}{
+		{"", "/", "", 200, []string{"Everything", "Acme status", "Globex homepage"}, []string{"Acme queue"}},
+		{"", "/", "secret", 200, []string{"Acme queue"}, nil},
+		{"", "/acme", "", http.StatusMovedPermanently, nil, nil},
+		{"", "/acme/", "secret", 200, []string{"Acme Status", "Acme status", `href="/acme/incidents"`}, []string{"Globex homepage", "Acme queue"}},
+		{"", "/acme/groups/Globex%20Web/checks/Globex%20homepage", "", 404, nil, nil},
+		{"", "/acme/api/v1/uptime", "", 200, []string{"Acme status"}, []string{"Globex"}},
+		{"status.globex.com:8080", "/", "", 200, []string{"Globex Status", "Globex homepage", `href="/incidents"`}, []string{"Acme"}},
+		{"STATUS.globex.com", "/feed.atom", "", 200, []string{"Globex Status", "http://STATUS.globex.com/"}, []string{"Acme"}},
+		{"", "/ops/", "", 401, nil, nil},
+		{"", "/ops/", "secret", 200, []string{"Operations", "Acme queue", "Globex homepage"}, nil},
+	} {
+		res := request(c.host, c.path, c.token)
+		if res.Code != c.status {
+			t.Error(fmt.Errorf("Expected %s%s to return %d, got: %d", c.host, c.path, c.status, res.Code))
+			continue
+		}
+		body := res.Body.String()
+		for _, str := range c.shown {
+			if !strings.Contains(body, str) {
+				t.Error(fmt.Errorf("Expected %s%s to contain '%s'", c.host, c.path, str))
+			}
+		}
+		for _, str := range c.hidden {
+			if strings.Contains(body, str) {
+				t.Error(fmt.Errorf("Expected %s%s to not contain '%s'", c.host, c.path, str))
+			}
+		}
+	}
+}
@@ -3,14 +3,23 @@ package patrol
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/NYTimes/gziphandler"
+	"github.com/karimsa/patrol/internal/announcement"
+	"github.com/karimsa/patrol/internal/auth"
 	"github.com/karimsa/patrol/internal/checker"
+	"github.com/karimsa/patrol/internal/events"
 	"github.com/karimsa/patrol/internal/history"
+	"github.com/karimsa/patrol/internal/incident"
 	"github.com/karimsa/patrol/internal/logger"
+	"github.com/karimsa/patrol/internal/silence"
+	"github.com/karimsa/patrol/internal/uptime"
 )
 
 // Options used to setup patrol's HTTP server.
@@ -21,6 +30,23 @@ type PatrolHttpsOptions struct {
 	// This port is used to run the HTTPS server. Zero value is invalid
 	// for port.
 	Port uint32
+
+	// Minimum TLS version accepted, from '1.0' to '1.3'. Zero value
+	// accepts TLS 1.2 and above.
+	MinVersion string `yaml:"minVersion"`
+
+	// Names of the cipher suites allowed for TLS 1.2 and below, as listed
+	// by the 'crypto/tls' package. Zero value uses the defaults of Go.
+	CipherSuites []string `yaml:"cipherSuites"`
+
+	// Path to a bundle of CA certificates. If set, clients must present a
+	// certificate signed by one of them.
+	ClientCA string `yaml:"clientCA"`
+
+	// Max age of the 'Strict-Transport-Security' header. Zero value does
+	// not send the header.
+	HSTS                  time.Duration `yaml:"hsts"`
+	HSTSIncludeSubdomains bool          `yaml:"hstsIncludeSubdomains"`
 }
 
 // Patrol instance to manage a set of checkers, a history file, and run
@@ -30,7 +56,11 @@ type Patrol struct {
 	History *history.File
 
 	name                string
+	listen              string
 	port                int
+	basePath            string
+	trustedProxies      []*net.IPNet
+	pages               []*Page
 	https               *PatrolHttpsOptions
 	checkers            []*checker.Checker
 	server              *http.Server
@@ -38,6 +68,21 @@ type Patrol struct {
 	logLevel            logger.LogLevel
 	groupEventHandlers  map[string]EventHandlers
 	globalEventHandlers EventHandlers
+	groupEscalations    map[string]EscalationPolicy
+	globalEscalation    EscalationPolicy
+	escalations         *escalationManager
+	silences            *silence.Store
+	auth                *auth.Authenticator
+	uptimeWindows       []time.Duration
+	incidents           *incident.Store
+	announcements       *announcement.Store
+	events              *events.Broker
+	charts              *chartCache
+
+	startedAt            time.Time
+	pendingNotifications int64
+	branding             Branding
+	views                *pageTemplates
 }
 
 // Map that goes from item status values to a list of notification objects
@@ -50,6 +95,19 @@ type CreatePatrolOptions struct {
 	// HTTP to HTTPS redirect server.
 	Port uint32
 
+	// Address (IP or hostname) that the HTTP and HTTPS servers bind to.
+	// Zero value listens on all interfaces.
+	Listen string
+
+	// Path under which the web interface and API are served, for when
+	// patrol runs behind a reverse proxy. Zero value serves from '/'.
+	BasePath string
+
+	// Proxies that are trusted to set the 'X-Forwarded-Proto' and
+	// 'X-Forwarded-Host' headers, which are used when generating absolute
+	// URLs and redirecting to HTTPS.
+	TrustedProxies []*net.IPNet
+
 	// HTTPS options to listen on HTTPS as well as HTTP.
 	// Zero value indicates no HTTPS server.
 	HTTPS *PatrolHttpsOptions
@@ -65,7 +123,8 @@ type CreatePatrolOptions struct {
 	History history.NewOptions
 
 	// Set of checkers that should be managed by the patrol instance.
-	// This slice cannot be nil, but it can be empty.
+	// This slice cannot be nil, but it can be empty. Services and checks
+	// are shown in the order that they first appear in.
 	Checkers []*checker.Checker
 
 	// Minimum level of logs that should be printed. This value is forced
@@ -78,6 +137,36 @@ type CreatePatrolOptions struct {
 
 	// Event handlers for all changes
 	GlobalEventHandlers EventHandlers
+
+	// Escalation policies by group
+	GroupEscalationPolicies map[string]EscalationPolicy
+
+	// Escalation policy applied to all checks
+	GlobalEscalationPolicy EscalationPolicy
+
+	// Users, tokens, and proxy headers that can be used to authenticate
+	// requests. Zero value allows anyone to view the status page, and
+	// disables API endpoints which modify state.
+	Auth auth.Options
+
+	// Bearer token with the admin scope, shorthand for adding it to the
+	// tokens of 'Auth'.
+	APIToken string
+
+	// Windows over which uptime percentages are shown on the status page.
+	// Zero value defaults to 24 hours, 7, 30, and 90 days.
+	UptimeWindows []time.Duration
+
+	// Logo, colors, and other options used to customize the web interface.
+	Branding Branding
+
+	// Directory containing templates that replace or extend the embedded
+	// templates of the web interface. Zero value uses the embedded templates.
+	TemplateDir string
+
+	// Additional status pages, each showing a selection of the services.
+	// Requests that do not match any of the pages are served the main page.
+	Pages []Page
 }
 
 func New(options CreatePatrolOptions, historyFile *history.File) (*Patrol, error) {
@@ -99,9 +188,60 @@ func New(options CreatePatrolOptions, historyFile *history.File) (*Patrol, error
 		}
 	}
 
+	silences, err := silence.Open(historyFile.Path() + ".silences")
+	if err != nil {
+		return nil, err
+	}
+	incidents, err := incident.Open(historyFile.Path()+".incidents", 1000, historyFile.GetData())
+	if err != nil {
+		return nil, err
+	}
+	announcements, err := announcement.Open(historyFile.Path() + ".announcements")
+	if err != nil {
+		return nil, err
+	}
+
+	authOptions := options.Auth
+	if options.APIToken != "" {
+		// 'apiToken' predates the 'auth' section, and on its own it keeps
+		// the status page public (unless 'anonymous' is set) as it always has
+		if authOptions.Anonymous == nil && len(authOptions.Users) == 0 && len(authOptions.Tokens) == 0 && authOptions.Proxy == nil {
+			anonymous := auth.ScopeRead
+			authOptions.Anonymous = &anonymous
+		}
+		authOptions.Tokens = append(authOptions.Tokens, auth.Token{
+			Name:  "apiToken",
+			Token: options.APIToken,
+			Scope: auth.ScopeAdmin,
+		})
+	}
+	authenticator, err := auth.New(authOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	basePath, err := normalizeBasePath(options.BasePath)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid base path: %s", err)
+	}
+
+	if err := options.Branding.validate(); err != nil {
+		return nil, err
+	}
+	views, err := loadTemplates(options.TemplateDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := views.validate(options.Branding); err != nil {
+		return nil, err
+	}
+
 	p := &Patrol{
 		name:                options.Name,
+		listen:              options.Listen,
 		port:                int(options.Port),
+		basePath:            basePath,
+		trustedProxies:      options.TrustedProxies,
 		https:               options.HTTPS,
 		checkers:            options.Checkers,
 		server:              &http.Server{},
@@ -109,13 +249,47 @@ func New(options CreatePatrolOptions, historyFile *history.File) (*Patrol, error
 		logger:              logger.New(options.LogLevel, ""),
 		groupEventHandlers:  options.GroupEventHandlers,
 		globalEventHandlers: options.GlobalEventHandlers,
+		groupEscalations:    options.GroupEscalationPolicies,
+		globalEscalation:    options.GlobalEscalationPolicy,
+		escalations:         newEscalationManager(),
+		silences:            silences,
+		auth:                authenticator,
+		uptimeWindows:       options.UptimeWindows,
+		incidents:           incidents,
+		announcements:       announcements,
+		events:              newEventBroker(historyFile.GetData()),
+		charts:              newChartCache(),
+		branding:            options.Branding,
+		views:               views,
 
 		History: historyFile,
 	}
-	p.server.Handler = gziphandler.GzipHandler(p)
+	if p.https != nil {
+		if p.server.TLSConfig, err = p.https.tlsConfig(p.logger); err != nil {
+			return nil, err
+		}
+	}
+	gzipHandler := gziphandler.GzipHandler(p)
+	p.server.Handler = http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.TLS != nil {
+			p.writeHSTS(res)
+		}
+		// Compression would hold back events until enough data is buffered
+		if strings.HasSuffix(req.URL.Path, "/api/v1/events") {
+			p.ServeHTTP(res, req)
+		} else {
+			gzipHandler.ServeHTTP(res, req)
+		}
+	})
 	if p.name == "" {
 		p.name = "Statuspage"
 	}
+	if err := p.setPages(options.Pages); err != nil {
+		return nil, err
+	}
+	if len(p.uptimeWindows) == 0 {
+		p.uptimeWindows = uptime.DefaultWindows
+	}
 	p.SetLogLevel(options.LogLevel)
 	return p, nil
 }
@@ -129,7 +303,9 @@ func (p *Patrol) String() string {
 	return strings.Join([]string{
 		fmt.Sprintf("Patrol{"),
 		fmt.Sprintf("\tname: %s,", p.name),
+		fmt.Sprintf("\tlisten: %s,", p.listen),
 		fmt.Sprintf("\tport: %d,", p.port),
+		fmt.Sprintf("\tbasePath: %s,", p.basePath),
 		fmt.Sprintf("\thttps: %#v,", p.https),
 		fmt.Sprintf("\tcheckers: %d checkers,", len(p.checkers)),
 		fmt.Sprintf("\tlogLevel: %d,", p.logLevel),
@@ -147,14 +323,119 @@ func (p *Patrol) SetLogLevel(level logger.LogLevel) {
 	}
 }
 
+func (p *Patrol) getChecker(group, name string) *checker.Checker {
+	for _, c := range p.checkers {
+		if c.Group == group && c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (p *Patrol) getLabels(group, name string) map[string]string {
+	if c := p.getChecker(group, name); c != nil {
+		return c.Labels
+	}
+	return nil
+}
+
+// Returns the end of the maintenance window that the given check is currently
+// in, if there is one.
+func (p *Patrol) inMaintenance(group, name string) (time.Time, bool) {
+	if c := p.getChecker(group, name); c != nil {
+		return c.Maintenance.Active(time.Now())
+	}
+	return time.Time{}, false
+}
+
+// Returns a non-empty reason if notifications for the given check should
+// not be sent.
+func (p *Patrol) suppressedBy(status, group, check string) string {
+	if _, ok := p.inMaintenance(group, check); ok || status == "maintenance" {
+		return "under maintenance"
+	}
+	if s, ok := p.silences.Find(group, check, p.getLabels(group, check)); ok {
+		return fmt.Sprintf("silenced by %s", s.ID)
+	}
+	if status == "unhealthy" && p.silences.IsAcknowledged(group, check) {
+		return "acknowledged"
+	}
+	return ""
+}
+
+func (p *Patrol) OnCheckerItem(item history.Item) {
+	p.publishItem(item)
+
+	i, err := p.incidents.Observe(item)
+	if err != nil {
+		p.logger.Warnf("Failed to save incidents: %s", err)
+	}
+	if i != nil && i.NumResults == 1 && !i.Resolved() {
+		p.logger.Infof("Incident opened: %s", i)
+	} else if i != nil && i.Resolved() {
+		p.logger.Infof("Incident resolved: %s", i)
+	}
+}
+
+// Incidents returns the incidents of the given group and check, newest first.
+// Empty values match all groups or checks.
+func (p *Patrol) Incidents(group, check string) []incident.Incident {
+	return p.incidents.List(group, check)
+}
+
+// How long resolved announcements remain on the status page.
+const resolvedAnnouncementTTL = 24 * time.Hour
+
+// Announcements returns the announcements that are shown on the status page:
+// all unresolved ones, and those resolved within the last day.
+func (p *Patrol) Announcements() []announcement.Announcement {
+	return p.announcements.List(time.Now().Add(-resolvedAnnouncementTTL))
+}
+
+// Returns true if the given group has at least one check.
+func (p *Patrol) hasGroup(group string) bool {
+	for _, checker := range p.checkers {
+		if checker.Group == group {
+			return true
+		}
+	}
+	return false
+}
+
+// Sends the notification in the background, keeping count of the
+// notifications that are still being sent.
+func (p *Patrol) notify(n *singleNotificationConfig) {
+	atomic.AddInt64(&p.pendingNotifications, 1)
+	go func() {
+		defer atomic.AddInt64(&p.pendingNotifications, -1)
+		n.Run()
+	}()
+}
+
 func (p *Patrol) OnCheckerStatus(status, group, checker string) {
 	p.logger.Debugf("status changed: %s, %s, %s", status, group, checker)
+	p.publishStatus(status, group, checker)
+
+	if status == "healthy" || status == "recovered" {
+		if cleared, err := p.silences.Unacknowledge(group, checker); err != nil {
+			p.logger.Warnf("Failed to clear acknowledgement of %s/%s: %s", group, checker, err)
+		} else if cleared {
+			p.logger.Infof("Cleared acknowledgement of %s/%s", group, checker)
+		}
+	}
+	if reason := p.suppressedBy(status, group, checker); reason != "" {
+		p.logger.Debugf("Skipping notifications for %s/%s: %s", group, checker, reason)
+		// Escalation steps check for silences as they become due, so that
+		// the escalation continues if the silence expires during an outage
+		p.escalate(status, group, checker)
+		return
+	}
 
 	if p.globalEventHandlers != nil {
 		if handlers, ok := p.globalEventHandlers[status]; ok && len(handlers) > 0 {
 			p.logger.Debugf("Sending global notification for %s status of %s", status, group)
 			for idx, n := range handlers {
-				n.Run()
+				p.notify(n)
 				p.logger.Debugf("Sent global notifcation #%d", idx)
 			}
 		}
@@ -163,11 +444,13 @@ func (p *Patrol) OnCheckerStatus(status, group, checker string) {
 		if handlers, ok := groupHandlers[status]; ok && len(handlers) > 0 {
 			p.logger.Debugf("Sending group notification for %s status of %s", status, group)
 			for idx, n := range handlers {
-				n.Run()
+				p.notify(n)
 				p.logger.Debugf("Sent group notifcation #%d", idx)
 			}
 		}
 	}
+
+	p.escalate(status, group, checker)
 }
 
 func (p *Patrol) Start() {
@@ -175,6 +458,7 @@ func (p *Patrol) Start() {
 		panic(fmt.Errorf("Cannot start patrol with zero checkers"))
 	}
 
+	p.startedAt = time.Now()
 	for _, checker := range p.checkers {
 		checker.Start(p)
 	}
@@ -182,25 +466,19 @@ func (p *Patrol) Start() {
 	go func() {
 		var err error
 		if p.https == nil {
-			p.server.Addr = fmt.Sprintf(":%d", p.port)
+			p.server.Addr = net.JoinHostPort(p.listen, strconv.Itoa(p.port))
 			err = p.server.ListenAndServe()
 		} else {
 			go func() {
-				err := http.ListenAndServe(fmt.Sprintf(":%d", p.port), http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
-					http.Redirect(
-						res,
-						req,
-						fmt.Sprintf("https://%s:%d", strings.Split(req.Host, ":")[0], p.https.Port),
-						http.StatusTemporaryRedirect,
-					)
-				}))
+				err := http.ListenAndServe(net.JoinHostPort(p.listen, strconv.Itoa(p.port)), http.HandlerFunc(p.redirectToHTTPS))
 				if err != nil && err != http.ErrServerClosed {
 					panic(err)
 				}
 			}()
 
-			p.server.Addr = fmt.Sprintf(":%d", p.https.Port)
-			err = p.server.ListenAndServeTLS(p.https.Cert, p.https.Key)
+			p.server.Addr = net.JoinHostPort(p.listen, strconv.Itoa(int(p.https.Port)))
+			// Certificates are loaded by the TLS config, so they can be reloaded
+			err = p.server.ListenAndServeTLS("", "")
 		}
 
 		if err != nil && err != http.ErrServerClosed {
@@ -213,6 +491,10 @@ func (p *Patrol) Stop() {
 	for _, checker := range p.checkers {
 		checker.Close()
 	}
+	p.stopEscalations()
+
+	// Event streams would otherwise hold up the shutdown of the server
+	p.events.Close()
 
 	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
 	defer cancel()
@@ -0,0 +1,127 @@
+package patrol
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/karimsa/patrol/internal/auth"
+)
+
+// Normalizes the path under which patrol is served to have a leading slash
+// and no trailing slash, so that it can be prefixed to absolute paths.
+func normalizeBasePath(basePath string) (string, error) {
+	basePath = strings.Trim(basePath, "/")
+	if basePath == "" {
+		return "", nil
+	}
+	u, err := url.Parse("/" + basePath)
+	if err != nil {
+		return "", err
+	}
+	if u.RawQuery != "" || u.Fragment != "" || u.Host != "" {
+		return "", fmt.Errorf("'%s' must be a path", basePath)
+	}
+	for _, segment := range strings.Split(basePath, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", fmt.Errorf("'%s' must be a path", basePath)
+		}
+	}
+	return "/" + basePath, nil
+}
+
+// Returns the first value of a header that was set by a trusted proxy. Proxies
+// append to these headers, so the first value is the one seen by the client.
+func (p *Patrol) forwardedHeader(req *http.Request, name string) string {
+	if len(p.trustedProxies) == 0 || !auth.FromTrustedProxy(req, p.trustedProxies) {
+		return ""
+	}
+	value := strings.Split(req.Header.Get(name), ",")[0]
+	return strings.TrimSpace(value)
+}
+
+// Returns the scheme and host of the request as seen by the client.
+func (p *Patrol) requestOrigin(req *http.Request) (scheme, host string) {
+	scheme = "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	if proto := p.forwardedHeader(req, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	host = req.Host
+	if forwardedHost := p.forwardedHeader(req, "X-Forwarded-Host"); forwardedHost != "" {
+		host = forwardedHost
+	}
+	return
+}
+
+// Returns the URL of the page that the request is for, as seen by the
+// client.
+func (p *Patrol) baseURL(req *http.Request) string {
+	scheme, host := p.requestOrigin(req)
+	return scheme + "://" + host + p.pagePath(req)
+}
+
+// Returns the request with the given prefix removed from the path of its
+// URL. Requests for the prefix itself are redirected to include a trailing
+// slash, and requests for other paths are rejected. 'mount' is the part of the
+// original path that was already removed from the request, which the redirect
+// has to keep.
+func stripPathPrefix(res http.ResponseWriter, req *http.Request, mount, prefix string) (*http.Request, bool) {
+	if prefix == "" {
+		return req, true
+	}
+	if req.URL.Path == prefix {
+		target := mount + prefix + "/"
+		if req.URL.RawQuery != "" {
+			target += "?" + req.URL.RawQuery
+		}
+		http.Redirect(res, req, target, http.StatusMovedPermanently)
+		return req, false
+	}
+	if !strings.HasPrefix(req.URL.Path, prefix+"/") {
+		res.WriteHeader(http.StatusNotFound)
+		res.Write([]byte("Not found"))
+		return req, false
+	}
+
+	u := *req.URL
+	u.Path = strings.TrimPrefix(u.Path, prefix)
+	u.RawPath = strings.TrimPrefix(u.RawPath, prefix)
+	stripped := req.WithContext(req.Context())
+	stripped.URL = &u
+	return stripped, true
+}
+
+// Redirects plain HTTP requests to the HTTPS server. Requests that a trusted
+// proxy already received over HTTPS are served directly, and the redirect
+// goes to the host the proxy was reached at. Browsers only honor the HSTS
+// header once it is received over HTTPS (such as through the proxy).
+func (p *Patrol) redirectToHTTPS(res http.ResponseWriter, req *http.Request) {
+	p.writeHSTS(res)
+	scheme, host := p.requestOrigin(req)
+	if scheme == "https" {
+		p.server.Handler.ServeHTTP(res, req)
+		return
+	}
+
+	if host == req.Host {
+		hostname := host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			hostname = h
+		}
+		hostname = strings.Trim(hostname, "[]")
+		if p.https.Port != 443 {
+			host = net.JoinHostPort(hostname, strconv.Itoa(int(p.https.Port)))
+		} else if strings.Contains(hostname, ":") {
+			host = "[" + hostname + "]"
+		} else {
+			host = hostname
+		}
+	}
+	http.Redirect(res, req, "https://"+host+req.URL.RequestURI(), http.StatusTemporaryRedirect)
+}
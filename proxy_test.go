@@ -0,0 +1,131 @@
+package patrol
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karimsa/patrol/internal/checker"
+	"github.com/karimsa/patrol/internal/history"
+)
+
+func TestBasePath(t *testing.T) {
+	removeTestDB("proxy-test.db")
+	defer removeTestDB("proxy-test.db")
+	historyFile, err := history.New(history.NewOptions{
+		File: "proxy-test.db",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer historyFile.Close()
+
+	if _, err := New(CreatePatrolOptions{Checkers: []*checker.Checker{}, BasePath: "/status/../admin"}, historyFile); err == nil {
+		t.Error(fmt.Errorf("Expected base path with '..' to be rejected"))
+	}
+
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	p, err := New(CreatePatrolOptions{
+		Checkers: []*checker.Checker{
+			checker.New(&checker.Checker{
+				Group:    "web",
+				Name:     "Homepage",
+				Cmd:      "true",
+				History:  historyFile,
+				Interval: 1 * time.Minute,
+			}),
+		},
+		BasePath:       "status/",
+		Pages:          []Page{{Name: "Web", Path: "/web", Services: []string{"web"}}},
+		TrustedProxies: []*net.IPNet{proxies},
+		HTTPS:          &PatrolHttpsOptions{Port: 8443},
+	}, historyFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := historyFile.Append(history.Item{Group: "web", Name: "Homepage", Type: "boolean", Status: "healthy"}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	request := func(handler http.HandlerFunc, path, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if remoteAddr != "" {
+			req.RemoteAddr = remoteAddr
+		}
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		res := httptest.NewRecorder()
+		handler(res, req)
+		return res
+	}
+
+	if res := request(p.ServeHTTP, "/status?group=web", "", nil); res.Code != http.StatusMovedPermanently || res.Header().Get("Location") != "/status/?group=web" {
+		t.Error(fmt.Errorf("Expected base path to redirect to a trailing slash, got: %d %s", res.Code, res.Header().Get("Location")))
+	}
+	if res := request(p.ServeHTTP, "/status/web?group=web", "", nil); res.Code != http.StatusMovedPermanently || res.Header().Get("Location") != "/status/web/?group=web" {
+		t.Error(fmt.Errorf("Expected page to redirect to a trailing slash within the base path, got: %d %s", res.Code, res.Header().Get("Location")))
+	}
+	if res := request(p.ServeHTTP, "/status/web/", "", nil); res.Code != http.StatusOK || !strings.Contains(res.Body.String(), `href="/status/web/incidents"`) {
+		t.Error(fmt.Errorf("Expected page to be served within the base path, got: %d", res.Code))
+	}
+	if res := request(p.ServeHTTP, "/", "", nil); res.Code != http.StatusNotFound {
+		t.Error(fmt.Errorf("Expected paths outside of the base path to return 404, got: %d", res.Code))
+	}
+
+	res := request(p.ServeHTTP, "/status/", "", nil)
+	for _, expected := range []string{
+		`href="/status/incidents"`,
+		`href="/status/groups/web/checks/Homepage"`,
+		`href="/status/?group=web"`,
+		`href="/status/feed.atom"`,
+		`new EventSource("/status" + '/api/v1/events')`,
+	} {
+		if !strings.Contains(res.Body.String(), expected) {
+			t.Error(fmt.Errorf("Expected status page to contain '%s'", expected))
+		}
+	}
+	for path, code := range map[string]int{
+		"/status/incidents":                  http.StatusOK,
+		"/status/groups/web/checks/Homepage": http.StatusOK,
+		"/status/api/v1/uptime":              http.StatusOK,
+		"/status/badge/web.svg":              http.StatusOK,
+	} {
+		if res := request(p.ServeHTTP, path, "", nil); res.Code != code {
+			t.Error(fmt.Errorf("Expected %s to return %d, got: %d", path, code, res.Code))
+		}
+	}
+
+	forwarded := map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "ops.example.com"}
+	if body := request(p.ServeHTTP, "/status/feed.atom", "10.0.0.1:1234", forwarded).Body.String(); !strings.Contains(body, "https://ops.example.com/status/") {
+		t.Error(fmt.Errorf("Expected feed from trusted proxy to use forwarded URL, got: %s", body))
+	}
+	if body := request(p.ServeHTTP, "/status/feed.atom", "192.168.0.1:1234", forwarded).Body.String(); strings.Contains(body, "ops.example.com") || !strings.Contains(body, "http://example.com/status/") {
+		t.Error(fmt.Errorf("Expected feed from untrusted client to ignore forwarded headers, got: %s", body))
+	}
+
+	for _, c := range []struct {
+		remoteAddr string
+		headers    map[string]string
+		location   string
+	}{
+		{"192.168.0.1:1234", nil, "https://example.com:8443/status/incidents"},
+		{"192.168.0.1:1234", forwarded, "https://example.com:8443/status/incidents"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-Host": "ops.example.com"}, "https://ops.example.com/status/incidents"},
+		{"10.0.0.1:1234", forwarded, ""},
+	} {
+		res := request(p.redirectToHTTPS, "/status/incidents", c.remoteAddr, c.headers)
+		if c.location == "" && res.Code != http.StatusOK {
+			t.Error(fmt.Errorf("Expected request forwarded over HTTPS to be served, got: %d", res.Code))
+		} else if c.location != "" && res.Header().Get("Location") != c.location {
+			t.Error(fmt.Errorf("Expected redirect to %s, got: %d %s", c.location, res.Code, res.Header().Get("Location")))
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package patrol
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/karimsa/patrol/internal/history"
+)
+
+// Time range that can be selected on the status page, which decides the
+// window of the charts and the size of each bar.
+type timeRange struct {
+	Name   string
+	Window time.Duration
+	Bucket time.Duration
+}
+
+var timeRanges = []timeRange{
+	{Name: "24h", Window: 24 * time.Hour, Bucket: time.Hour},
+	{Name: "7d", Window: 7 * 24 * time.Hour, Bucket: time.Hour},
+	{Name: "30d", Window: 30 * 24 * time.Hour, Bucket: 24 * time.Hour},
+	{Name: "90d", Window: 90 * 24 * time.Hour, Bucket: 24 * time.Hour},
+}
+
+func findRange(name string) (timeRange, bool) {
+	for _, r := range timeRanges {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return timeRange{}, false
+}
+
+// Link to one of the ranges, shown as a selector on the status page.
+type rangeOption struct {
+	Label  string
+	URL    string
+	Active bool
+}
+
+// Returns a link for each range (and for the most recent results), keeping
+// the rest of the query intact.
+func rangeOptions(path string, query url.Values, selected string) []rangeOption {
+	options := make([]rangeOption, 0, len(timeRanges)+1)
+	for _, name := range append([]string{""}, rangeNames()...) {
+		q := url.Values{}
+		for key, values := range query {
+			q[key] = values
+		}
+		q.Del("range")
+		if name != "" {
+			q.Set("range", name)
+		}
+		option := rangeOption{Label: name, URL: path, Active: name == selected}
+		if option.Label == "" {
+			option.Label = "Recent"
+		}
+		if encoded := q.Encode(); encoded != "" {
+			option.URL += "?" + encoded
+		}
+		options = append(options, option)
+	}
+	return options
+}
+
+func rangeNames() []string {
+	names := make([]string, len(timeRanges))
+	for idx, r := range timeRanges {
+		names[idx] = r.Name
+	}
+	return names
+}
+
+// Results of a check within a single bar, summarized by the most severe
+// status among them.
+type bucket struct {
+	Start, End time.Time
+	Status     string
+}
+
+// Title is shown as a tooltip for the bar.
+func (b bucket) Title() string {
+	when := b.Start.Format("Jan 2 15:04") + " - " + b.End.Format("15:04 MST")
+	if b.End.Sub(b.Start) >= 24*time.Hour {
+		when = b.Start.Format("Jan 2 MST")
+	}
+	if b.Status == "" {
+		return when + ": no results"
+	}
+	return when + ": " + b.Status
+}
+
+// Returns the end of the bucket containing the given time. Buckets are cut
+// in UTC, like the days of boolean items in the history.
+func bucketEnd(now time.Time, size time.Duration) time.Time {
+	return now.UTC().Truncate(size).Add(size)
+}
+
+// Groups the items of a boolean check (newest first) into buckets covering
+// the window of the range, oldest first. Boolean checks only keep an item
+// for each day, so buckets are filled from the status of each hour of the
+// item rather than from the item itself.
+func bucketItems(items []history.Item, r timeRange, now time.Time) []bucket {
+	numBuckets := int(r.Window / r.Bucket)
+	end := bucketEnd(now, r.Bucket)
+	start := end.Add(-time.Duration(numBuckets) * r.Bucket)
+
+	buckets := make([]bucket, numBuckets)
+	for idx := range buckets {
+		buckets[idx].Start = start.Add(time.Duration(idx) * r.Bucket)
+		buckets[idx].End = buckets[idx].Start.Add(r.Bucket)
+	}
+	for _, item := range items {
+		day := item.CreatedAt.UTC().Truncate(24 * time.Hour)
+		if !day.Add(24 * time.Hour).After(start) {
+			break
+		}
+		for hour := 0; hour < 24; hour++ {
+			status := item.HourStatus(hour)
+			at := day.Add(time.Duration(hour) * time.Hour)
+			if status == "" || at.Before(start) || !at.Before(end) {
+				continue
+			}
+			b := &buckets[int(at.Sub(start)/r.Bucket)]
+			if statusSeverity[status] > statusSeverity[b.Status] {
+				b.Status = status
+			}
+		}
+	}
+	return buckets
+}
+
+// Returns how much of the window of the range the items (newest first)
+// cover, or zero if they reach back to its start. The history only keeps a
+// limited number of results for each check, which might not cover the
+// longer ranges.
+func rangeCoverage(items []history.Item, r timeRange, now time.Time) time.Duration {
+	if len(items) == 0 {
+		return 0
+	}
+	oldest := items[len(items)-1]
+	if covered := now.Sub(oldest.CreatedAt.Add(-oldest.Accumulated())); covered < r.Window {
+		return covered
+	}
+	return 0
+}
+
+// Returns the items (newest first) created within the window of the range.
+func itemsWithin(items []history.Item, r timeRange, now time.Time) []history.Item {
+	cutoff := now.Add(-r.Window)
+	for idx, item := range items {
+		if item.CreatedAt.Before(cutoff) {
+			return items[:idx]
+		}
+	}
+	return items
+}
@@ -0,0 +1,161 @@
+package patrol
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karimsa/patrol/internal/checker"
+	"github.com/karimsa/patrol/internal/history"
+)
+
+func TestBucketItems(t *testing.T) {
+	removeTestDB("ranges-buckets-test.db")
+	defer removeTestDB("ranges-buckets-test.db")
+	historyFile, err := history.New(history.NewOptions{
+		File: "ranges-buckets-test.db",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var outage history.Item
+	for _, status := range []string{"healthy", "unhealthy", "healthy"} {
+		item, err := historyFile.Append(history.Item{Group: "web", Name: "Homepage", Type: "boolean", Status: status})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if status == "unhealthy" {
+			outage = item
+		}
+	}
+	historyFile.Close()
+
+	// Hourly statuses are kept in the data file
+	historyFile, err = history.New(history.NewOptions{
+		File: "ranges-buckets-test.db",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer historyFile.Close()
+	items := historyFile.GetGroupItems("web", "Homepage")
+	if len(items) != 1 || items[0].Status != "recovered" {
+		t.Error(fmt.Errorf("Expected results to be kept as a single recovered item, got: %v", items))
+		return
+	}
+	now := items[0].CreatedAt
+
+	r, _ := findRange("24h")
+	buckets := bucketItems(items, r, now)
+	if len(buckets) != 24 || !buckets[23].Start.Equal(now.UTC().Truncate(time.Hour)) {
+		t.Error(fmt.Errorf("Expected 24 buckets ending with the current hour, got: %d", len(buckets)))
+		return
+	}
+	hoursAgo := int(now.UTC().Truncate(time.Hour).Sub(outage.CreatedAt.UTC().Truncate(time.Hour)) / time.Hour)
+	if b := buckets[23-hoursAgo]; b.Status != "unhealthy" {
+		t.Error(fmt.Errorf("Expected outage to be kept after the check recovered, got: %s", b.Title()))
+	}
+	if b := buckets[0]; b.Status != "" || !strings.HasSuffix(b.Title(), " UTC: no results") {
+		t.Error(fmt.Errorf("Expected first bucket to be empty, got: %s", b.Title()))
+	}
+
+	r, _ = findRange("30d")
+	buckets = bucketItems(items, r, now)
+	today := now.UTC().Truncate(24 * time.Hour)
+	if len(buckets) != 30 || !buckets[29].End.Equal(today.Add(24*time.Hour)) {
+		t.Error(fmt.Errorf("Expected daily buckets to end at midnight UTC, got: %d buckets ending %s", len(buckets), buckets[len(buckets)-1].End))
+		return
+	}
+	if b := buckets[29]; b.Title() != today.Format("Jan 2 MST")+": unhealthy" {
+		t.Error(fmt.Errorf("Expected today to be unhealthy, got: %s", b.Title()))
+	}
+	if b := buckets[28]; b.Status != "" {
+		t.Error(fmt.Errorf("Expected yesterday to have no results, got: %s", b.Title()))
+	}
+
+	// Metric checks keep every result
+	metrics := []history.Item{
+		{Type: "metric", CreatedAt: now.Add(-10 * time.Minute)},
+		{Type: "metric", CreatedAt: now.Add(-3 * time.Hour)},
+		{Type: "metric", CreatedAt: now.Add(-48 * time.Hour)},
+	}
+	if within := itemsWithin(metrics, r, now); len(within) != 3 {
+		t.Error(fmt.Errorf("Expected all items to be within 30 days, got: %d", len(within)))
+	}
+	r, _ = findRange("24h")
+	if within := itemsWithin(metrics, r, now); len(within) != 2 {
+		t.Error(fmt.Errorf("Expected 2 items to be within 24 hours, got: %d", len(within)))
+	}
+
+	// Only the latest results are kept, which might not cover the range
+	if covered := rangeCoverage(metrics, r, now); covered != 0 {
+		t.Error(fmt.Errorf("Expected 24 hours to be fully covered, got: %s", covered))
+	}
+	r, _ = findRange("7d")
+	if covered := rangeCoverage(metrics, r, now); covered != 48*time.Hour {
+		t.Error(fmt.Errorf("Expected 48 hours of 7 days to be covered, got: %s", covered))
+	}
+}
+
+func TestRangeSelector(t *testing.T) {
+	removeTestDB("ranges-test.db")
+	defer removeTestDB("ranges-test.db")
+	historyFile, err := history.New(history.NewOptions{
+		File: "ranges-test.db",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer historyFile.Close()
+
+	p, err := New(CreatePatrolOptions{
+		Checkers: []*checker.Checker{
+			checker.New(&checker.Checker{
+				Group:    "web",
+				Name:     "Homepage",
+				Cmd:      "true",
+				History:  historyFile,
+				Interval: 1 * time.Minute,
+			}),
+		},
+	}, historyFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := historyFile.Append(history.Item{Group: "web", Name: "Homepage", Type: "boolean", Status: "healthy"}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	request := func(path string) string {
+		res := httptest.NewRecorder()
+		p.ServeHTTP(res, httptest.NewRequest("GET", path, nil))
+		return res.Body.String()
+	}
+
+	body := request("/?group=web&range=7d")
+	for _, expected := range []string{
+		`href="/?group=web"`,
+		`href="/?group=web&amp;range=90d"`,
+		`viewBox="0 0 670 10"`,
+		`href="/groups/web/checks/Homepage?from=`,
+		" UTC: healthy",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Error(fmt.Errorf("Expected page with range to contain '%s'", expected))
+		}
+	}
+
+	body = request("/?range=1y")
+	if strings.Contains(body, "?from=") || !strings.Contains(body, `viewBox="0 0 318 10"`) {
+		t.Error(fmt.Errorf("Expected unknown range to show the most recent results"))
+	}
+}
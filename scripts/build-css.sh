@@ -8,10 +8,12 @@ mkdir -p dist
 # }} <
 # }} {{
 # > <
-cat index.html \
-    | tr -d '\n' \
-    | sed -E 's/([>\}\}])[[:space:]]+([<\{\{])/\1\2/g' \
-    | tr -s ' ' > dist/index.html
+for file in *.html; do
+    cat "$file" \
+        | tr -d '\n' \
+        | sed -E 's/([>\}\}])[[:space:]]+([<\{\{])/\1\2/g' \
+        | tr -s ' ' > "dist/$file"
+done
 
 css=`mktemp`
 tailwindcss build \
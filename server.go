@@ -1,174 +1,129 @@
 package patrol
 
 import (
-	"bytes"
 	_ "embed"
-	"encoding/base64"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/andanhm/go-prettytime"
-	"github.com/wcharczuk/go-chart"
-	"github.com/wcharczuk/go-chart/drawing"
 
+	"github.com/karimsa/patrol/internal/announcement"
+	"github.com/karimsa/patrol/internal/auth"
 	"github.com/karimsa/patrol/internal/history"
+	"github.com/karimsa/patrol/internal/incident"
 	"github.com/karimsa/patrol/internal/logger"
+	"github.com/karimsa/patrol/internal/silence"
+	"github.com/karimsa/patrol/internal/uptime"
 )
 
-type chartResult struct {
-	SVG           string
-	Min, Max, Avg float64
-	Error         string
+type maintenanceNotice struct {
+	Group, Check string
+	Until        time.Time
 }
 
 //go:embed dist/index.html
 var indexHTML string
 
+//go:embed dist/incidents.html
+var incidentsHTML string
+
+//go:embed dist/check.html
+var checkHTML string
+
+//go:embed dist/partials.html
+var partialsHTML string
+
 //go:embed dist/styles.css
 var stylesCSS string
 
 var (
-	gridMajorStyle = chart.Style{
-		Show:        true,
-		StrokeWidth: 1.5,
-		StrokeColor: drawing.Color{
-			A: 90,
-		},
-	}
-	gridMinorStyle = chart.Style{
-		Show:        true,
-		StrokeWidth: 1.0,
-		StrokeColor: drawing.Color{
-			A: 50,
+	templateFuncs = template.FuncMap{
+		"mul": func(a, b int) int {
+			return a * b
 		},
-	}
-	seriesStyle = chart.Style{
-		Show:        true,
-		FontColor:   drawing.ColorBlack,
-		StrokeWidth: 3,
-	}
-	metricChartDefaults = chart.Chart{
-		XAxis: chart.XAxis{
-			Style:          chart.StyleShow(),
-			GridMajorStyle: gridMajorStyle,
-			GridMinorStyle: gridMinorStyle,
+		"sub": func(a, b int) int {
+			return a - b
 		},
-		YAxis: chart.YAxis{
-			Style:          chart.StyleShow(),
-			GridMajorStyle: gridMajorStyle,
-			GridMinorStyle: gridMinorStyle,
+		"plus": func(a, b int) int {
+			return a + b
 		},
-	}
-	pageView = template.Must(
-		template.New("index").Funcs(template.FuncMap{
-			"mul": func(a, b int) int {
-				return a * b
-			},
-			"sub": func(a, b int) int {
-				return a - b
-			},
-			"plus": func(a, b int) int {
-				return a + b
-			},
-			"nums": func(a, b int) []int {
-				if a > b {
-					r := make([]int, a-b+1)
-					for i := 0; a >= b; i++ {
-						r[i] = a
-						a--
-					}
-					return r
-				}
-
-				r := make([]int, b-a+1)
-				for i := 0; a <= b; i++ {
+		"nums": func(a, b int) []int {
+			if a > b {
+				r := make([]int, a-b+1)
+				for i := 0; a >= b; i++ {
 					r[i] = a
-					a++
+					a--
 				}
 				return r
-			},
-			"since": prettytime.Format,
-			"fmtNum": func(n float64) string {
-				parts := strings.Split(fmt.Sprintf("%.2f", n), ".")
-				for i := len(parts[0]) - 3; i > 0; i -= 3 {
-					parts[0] = parts[0][0:i] + ", " + parts[0][i:]
-				}
-				return parts[0] + "." + parts[1]
-			},
-			"chart": func(items []history.Item) chartResult {
-				if len(items) < 1 {
-					return chartResult{Error: "Data pending"}
-				}
-
-				res := chartResult{
-					Min: items[0].Metric,
-					Max: items[0].Metric,
-					Avg: 0,
-				}
-				xValues := make([]time.Time, len(items))
-				yValues := make([]float64, len(items))
-				for i, item := range items {
-					xValues[i] = item.CreatedAt
-					yValues[i] = item.Metric
-
-					if res.Min > item.Metric {
-						res.Min = item.Metric
-					}
-					if res.Max < item.Metric {
-						res.Max = item.Metric
-					}
-					res.Avg += item.Metric
-				}
-				res.Avg /= float64(len(items))
-
-				c := metricChartDefaults
-				c.Series = []chart.Series{
-					chart.TimeSeries{
-						XValues: xValues,
-						YValues: yValues,
-						Style:   seriesStyle,
-					},
-				}
-
-				// go-chart cannot draw constant functions
-				if res.Min == res.Max {
-					c.YAxis.Range = &chart.ContinuousRange{
-						Min: res.Min - 1,
-						Max: res.Max + 1,
-					}
-				}
-				if len(items) == 1 {
-					c.XAxis.Range = &chart.ContinuousRange{
-						Min: float64(items[0].CreatedAt.UnixNano() - int64(24*time.Hour)),
-						Max: float64(items[0].CreatedAt.UnixNano() + int64(24*time.Hour)),
-					}
-				} else if len(items) == 0 {
-					c.XAxis.Range = &chart.ContinuousRange{
-						Min: 0,
-						Max: 1,
-					}
-				}
+			}
 
-				buffer := bytes.Buffer{}
-				if err := c.Render(chart.SVG, &buffer); err != nil {
-					res.Error = fmt.Sprintf("Failed to render graph: %s", err)
-				} else {
-					res.SVG = base64.StdEncoding.EncodeToString(buffer.Bytes())
-				}
-				return res
-			},
-		}).Parse(indexHTML),
-	)
+			r := make([]int, b-a+1)
+			for i := 0; a <= b; i++ {
+				r[i] = a
+				a++
+			}
+			return r
+		},
+		"since":  prettytime.Format,
+		"window": uptime.FormatWindow,
+		"fmtNum": func(n float64) string {
+			parts := strings.Split(fmt.Sprintf("%.2f", n), ".")
+			for i := len(parts[0]) - 3; i > 0; i -= 3 {
+				parts[0] = parts[0][0:i] + ", " + parts[0][i:]
+			}
+			return parts[0] + "." + parts[1]
+		},
+		"chart": chartSummary,
+		"fmtTime": func(t time.Time) string {
+			return t.Format("Jan 2 2006 15:04:05 MST")
+		},
+		"fmtDuration": func(d time.Duration) string {
+			return d.Round(time.Second).String()
+		},
+		"pathEscape": url.PathEscape,
+		"statusColor": func(status string) string {
+			switch status {
+			case "healthy":
+				return "#38a169"
+			case "unhealthy":
+				return "#c05621"
+			case "recovered":
+				return "#9b2c2c"
+			case "maintenance":
+				return "#2b6cb0"
+			}
+			return "#d9dbde"
+		},
+		"buckets": func(items []history.Item, rangeName string) []bucket {
+			if r, ok := findRange(rangeName); ok {
+				return bucketItems(items, r, time.Now())
+			}
+			return nil
+		},
+		"coverage": func(items []history.Item, rangeName string) time.Duration {
+			if r, ok := findRange(rangeName); ok {
+				return rangeCoverage(items, r, time.Now())
+			}
+			return 0
+		},
+		"covered": uptime.FormatCovered,
+		"within": func(items []history.Item, rangeName string) []history.Item {
+			if r, ok := findRange(rangeName); ok {
+				return itemsWithin(items, r, time.Now())
+			}
+			return items
+		},
+	}
 )
 
-func init() {
-	template.Must(pageView.New("styles.css").Parse(stylesCSS))
-}
+// Number of results shown per page on the check details page.
+const checkPageSize = 50
 
 func (p *Patrol) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	defer func() {
@@ -177,33 +132,345 @@ func (p *Patrol) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		}
 	}()
 
+	if path := strings.TrimPrefix(req.URL.Path, p.basePath); path == "/healthz" || path == "/readyz" {
+		p.serveHealth(res, req, path == "/readyz")
+		return
+	}
+
+	req, ok := p.routeRequest(res, req)
+	if !ok {
+		return
+	}
+
+	id, err := p.auth.Authenticate(req)
+	if err != nil {
+		p.writeUnauthorized(res, req, err.Error())
+		return
+	}
+	if id.Scope < auth.ScopeRead || (id.Anonymous() && pageOf(req) != nil && pageOf(req).Visibility == "internal") {
+		p.writeUnauthorized(res, req, "Authentication required")
+		return
+	}
+	req = req.WithContext(auth.WithIdentity(req.Context(), id))
+
+	if strings.HasPrefix(req.URL.Path, "/api/") {
+		p.serveAPI(res, req)
+		return
+	}
+	if req.URL.Path == "/incidents" || strings.HasPrefix(req.URL.Path, "/incidents/") {
+		p.serveIncidents(res, req)
+		return
+	}
+	if strings.HasPrefix(req.URL.Path, "/groups/") {
+		p.serveCheck(res, req)
+		return
+	}
+	if strings.HasPrefix(req.URL.Path, "/items/") {
+		p.serveItem(res, req)
+		return
+	}
+	if req.URL.Path == "/feed.atom" || req.URL.Path == "/feed.rss" {
+		p.serveFeed(res, req)
+		return
+	}
+	if strings.HasPrefix(req.URL.Path, "/badge/") {
+		p.serveBadge(res, req)
+		return
+	}
+	if strings.HasPrefix(req.URL.Path, "/charts/") {
+		p.serveChart(res, req)
+		return
+	}
+	p.serveIndex(res, req)
+}
+
+// Responds with a 401, asking browsers to prompt for a username and password
+// if there are users that can log in.
+func (p *Patrol) writeUnauthorized(res http.ResponseWriter, req *http.Request, message string) {
+	if p.auth.HasUsers() {
+		res.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", p.name))
+	}
+	if strings.HasPrefix(req.URL.Path, "/api/") {
+		writeJSON(res, http.StatusUnauthorized, apiError{message})
+		return
+	}
+	res.WriteHeader(http.StatusUnauthorized)
+	res.Write([]byte(message))
+}
+
+// Splits the path of the request into unescaped segments, so that group and
+// check names containing slashes can be used in paths.
+func pathSegments(req *http.Request) []string {
+	segments := strings.Split(strings.Trim(req.URL.EscapedPath(), "/"), "/")
+	for idx, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segments[idx] = unescaped
+		}
+	}
+	return segments
+}
+
+// Parses a time given in a query parameter, either in RFC3339 format or in
+// the format used by 'datetime-local' inputs.
+func parseTimeParam(str string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, str); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04", str, time.Local)
+}
+
+// Data given to the 'index.html' template.
+type indexPage struct {
+	Name            string
+	BasePath        string
+	Branding        Branding
+	Groups          map[string]map[string][]history.Item
+	OrderedGroups   []GroupData
+	NumServicesDown int
+	NumServices     int
+	LatestCreatedAt time.Time
+	GroupFilter     string
+	StatusFilter    string
+	Range           string
+	Ranges          []rangeOption
+	Debug           bool
+	Silenced        map[string]*silence.Silence
+	Acknowledged    map[string]bool
+	CanWrite        bool
+	Maintenance     []maintenanceNotice
+	Uptime          map[string]GroupUptime
+	Announcements   []announcement.Announcement
+}
+
+// Data given to the 'incidents.html' template.
+type incidentsPage struct {
+	Name        string
+	BasePath    string
+	Branding    Branding
+	GroupFilter string
+	CheckFilter string
+	Incidents   []incident.Incident
+	Incident    *incident.Incident
+	Items       []history.Item
+}
+
+// Data given to the 'check.html' template.
+type checkPage struct {
+	Name         string
+	BasePath     string
+	Branding     Branding
+	Group        string
+	Check        string
+	Items        []history.Item
+	Item         *history.Item
+	From, To     string
+	StatusFilter string
+	Page         int
+	NumPages     int
+	NumItems     int
+	PrevURL      string
+	NextURL      string
+}
+
+func (p *Patrol) renderCheckPage(res http.ResponseWriter, req *http.Request, data checkPage) {
+	data.Name = p.pageName(req)
+	data.BasePath = p.pagePath(req)
+	data.Branding = p.branding
+	p.render(res, p.views.check, data)
+}
+
+func (p *Patrol) serveCheck(res http.ResponseWriter, req *http.Request) {
+	segments := pathSegments(req)
+	if len(segments) != 4 || segments[2] != "checks" {
+		res.WriteHeader(http.StatusNotFound)
+		res.Write([]byte("Check not found"))
+		return
+	}
+	allItems := p.History.GetGroupItems(segments[1], segments[3])
+	if (len(allItems) == 0 && p.getChecker(segments[1], segments[3]) == nil) || !p.canSee(req, segments[1], segments[3]) {
+		res.WriteHeader(http.StatusNotFound)
+		res.Write([]byte("Check not found"))
+		return
+	}
+
+	query := req.URL.Query()
+	data := checkPage{
+		Group:        segments[1],
+		Check:        segments[3],
+		From:         query.Get("from"),
+		To:           query.Get("to"),
+		StatusFilter: query.Get("status"),
+		Page:         1,
+	}
+
+	var from, to time.Time
+	var err error
+	if data.From != "" {
+		if from, err = parseTimeParam(data.From); err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+			res.Write([]byte("Invalid 'from' time: " + err.Error()))
+			return
+		}
+	}
+	if data.To != "" {
+		if to, err = parseTimeParam(data.To); err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+			res.Write([]byte("Invalid 'to' time: " + err.Error()))
+			return
+		}
+	}
+	if page, err := strconv.Atoi(query.Get("page")); err == nil && page > 0 {
+		data.Page = page
+	}
+
+	var items []history.Item
+	for _, item := range p.sanitizeItems(req, allItems) {
+		if !from.IsZero() && item.CreatedAt.Before(from) {
+			continue
+		}
+		if !to.IsZero() && item.CreatedAt.After(to) {
+			continue
+		}
+		if data.StatusFilter != "" && item.Status != data.StatusFilter {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	data.NumItems = len(items)
+	data.NumPages = (len(items) + checkPageSize - 1) / checkPageSize
+	if start := (data.Page - 1) * checkPageSize; start < len(items) {
+		end := start + checkPageSize
+		if end > len(items) {
+			end = len(items)
+		}
+		data.Items = items[start:end]
+	}
+
+	pageURL := func(page int) string {
+		query.Set("page", strconv.Itoa(page))
+		return p.pagePath(req) + req.URL.EscapedPath() + "?" + query.Encode()
+	}
+	if data.Page > 1 {
+		data.PrevURL = pageURL(data.Page - 1)
+	}
+	if data.Page < data.NumPages {
+		data.NextURL = pageURL(data.Page + 1)
+	}
+
+	p.renderCheckPage(res, req, data)
+}
+
+func (p *Patrol) serveItem(res http.ResponseWriter, req *http.Request) {
+	segments := pathSegments(req)
+	if len(segments) != 2 {
+		res.WriteHeader(http.StatusNotFound)
+		res.Write([]byte("Item not found"))
+		return
+	}
+	item, ok := p.History.GetItem(segments[1])
+	if !ok || !p.canSee(req, item.Group, item.Name) {
+		res.WriteHeader(http.StatusNotFound)
+		res.Write([]byte("Item not found"))
+		return
+	}
+
+	item = p.sanitizeItem(req, item)
+	p.renderCheckPage(res, req, checkPage{
+		Group: item.Group,
+		Check: item.Name,
+		Item:  &item,
+	})
+}
+
+func (p *Patrol) serveIncidents(res http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	data := incidentsPage{
+		Name:        p.pageName(req),
+		BasePath:    p.pagePath(req),
+		Branding:    p.branding,
+		GroupFilter: query.Get("group"),
+		CheckFilter: query.Get("check"),
+	}
+
+	if id := strings.TrimPrefix(req.URL.Path, "/incidents/"); id != req.URL.Path {
+		i, ok := p.incidents.Get(id)
+		if ok {
+			i, ok = p.visibleIncident(req, i)
+		}
+		if !ok {
+			res.WriteHeader(http.StatusNotFound)
+			res.Write([]byte("Incident not found"))
+			return
+		}
+		data.Incident = &i
+
+		// Results that were recorded during the incident
+		end := i.RecoveredAt
+		if !i.Resolved() {
+			end = time.Now()
+		}
+		for _, item := range p.sanitizeItems(req, p.History.GetGroupItems(i.Group, i.Check)) {
+			if !item.CreatedAt.Before(i.Start) && !item.CreatedAt.After(end) {
+				data.Items = append(data.Items, item)
+			}
+		}
+	} else {
+		data.Incidents = p.visibleIncidents(req, p.incidents.List(data.GroupFilter, data.CheckFilter))
+	}
+
+	p.render(res, p.views.incidents, data)
+}
+
+func (p *Patrol) serveIndex(res http.ResponseWriter, req *http.Request) {
 	query, err := url.ParseQuery(req.URL.RawQuery)
 	if err != nil {
 		log.Printf("warn: Query parsing failed: %s", err)
 	}
 
-	data := struct {
-		Name            string
-		Groups          map[string]map[string][]history.Item
-		NumServicesDown int
-		NumServices     int
-		LatestCreatedAt time.Time
-		GroupFilter     string
-		StatusFilter    string
-		Debug           bool
-	}{
-		Name:            p.name,
-		Groups:          p.History.GetData(),
+	groups := p.visibleData(req)
+	data := indexPage{
+		Name:            p.pageName(req),
+		BasePath:        p.pagePath(req),
+		Branding:        p.branding,
+		Groups:          groups,
+		OrderedGroups:   p.groupData(groups),
 		NumServicesDown: 0,
 		NumServices:     0,
 		LatestCreatedAt: time.Unix(0, 0),
 		GroupFilter:     query.Get("group"),
 		StatusFilter:    query.Get("status"),
 		Debug:           p.logLevel == logger.LevelDebug,
+		Silenced:        make(map[string]*silence.Silence),
+		Acknowledged:    make(map[string]bool),
+		CanWrite:        auth.FromContext(req.Context()).Scope >= auth.ScopeWrite,
+		Uptime:          p.visibleUptime(req, nil),
+		Announcements:   p.visibleAnnouncements(req, p.Announcements()),
 	}
 
-	for _, group := range data.Groups {
-		for _, items := range group {
+	for _, c := range p.checkers {
+		if !p.canSee(req, c.Group, c.Name) {
+			continue
+		}
+		if until, ok := c.Maintenance.Active(time.Now()); ok {
+			data.Maintenance = append(data.Maintenance, maintenanceNotice{
+				Group: c.Group,
+				Check: c.Name,
+				Until: until,
+			})
+		}
+	}
+
+	for _, group := range data.OrderedGroups {
+		groupName := group.Name
+		for _, check := range group.Checks {
+			checkName, items := check.Name, check.Items
+			key := escalationKey(groupName, checkName)
+			if s, ok := p.silences.Find(groupName, checkName, p.getLabels(groupName, checkName)); ok {
+				data.Silenced[key] = &s
+			}
+			data.Acknowledged[key] = p.silences.IsAcknowledged(groupName, checkName)
+
 			if len(items) > 0 {
 				if items[0].Status == "unhealthy" {
 					data.NumServicesDown++
@@ -216,9 +483,10 @@ func (p *Patrol) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		}
 	}
 
-	if err := pageView.Execute(res, data); err != nil {
-		p.logger.Warnf("Failed to execute template: %s", err)
-		res.WriteHeader(500)
-		res.Write([]byte(err.Error()))
+	if _, ok := findRange(query.Get("range")); ok {
+		data.Range = query.Get("range")
 	}
+	data.Ranges = rangeOptions(data.BasePath+"/", query, data.Range)
+
+	p.render(res, p.views.index, data)
 }
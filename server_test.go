@@ -1,19 +1,34 @@
 package patrol
 
 import (
+	"bufio"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/karimsa/patrol/internal/auth"
 	"github.com/karimsa/patrol/internal/checker"
 	"github.com/karimsa/patrol/internal/history"
+	"github.com/karimsa/patrol/internal/silence"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// Removes a test database along with the files that patrol keeps next to it.
+func removeTestDB(path string) {
+	for _, suffix := range []string{"", ".incidents", ".silences", ".announcements"} {
+		os.Remove(path + suffix)
+	}
+}
+
 func TestServer(t *testing.T) {
-	os.Remove("server-test.db")
+	removeTestDB("server-test.db")
+	defer removeTestDB("server-test.db")
 	historyFile, err := history.New(history.NewOptions{
 		File: "server-test.db",
 	})
@@ -66,3 +81,643 @@ func TestServer(t *testing.T) {
 
 	p.Close()
 }
+
+func TestCheckPages(t *testing.T) {
+	removeTestDB("server-pages-test.db")
+	defer removeTestDB("server-pages-test.db")
+	historyFile, err := history.New(history.NewOptions{
+		File: "server-pages-test.db",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer historyFile.Close()
+
+	p, err := New(CreatePatrolOptions{
+		Checkers: []*checker.Checker{
+			checker.New(&checker.Checker{
+				Group:    "web/app",
+				Name:     "API status",
+				Cmd:      "true",
+				History:  historyFile,
+				Interval: 1 * time.Minute,
+			}),
+		},
+	}, historyFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var items []history.Item
+	for i := 0; i < checkPageSize+1; i++ {
+		item, err := historyFile.Append(history.Item{
+			Group:    "web/app",
+			Name:     "API status",
+			Type:     "metric",
+			Output:   []byte(fmt.Sprintf("output of run %d", i)),
+			Status:   "healthy",
+			Attempts: 1,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		items = append(items, item)
+	}
+
+	for _, c := range []struct {
+		path     string
+		status   int
+		contains string
+	}{
+		{"/groups/web%2Fapp/checks/API%20status", 200, fmt.Sprintf("output of run %d", checkPageSize)},
+		{"/groups/web%2Fapp/checks/API%20status?page=2", 200, "output of run 0"},
+		{"/groups/web%2Fapp/checks/API%20status?status=unhealthy", 200, "No results recorded"},
+		{"/groups/web%2Fapp/checks/API%20status?from=bad", 400, ""},
+		{"/groups/web%2Fapp/checks/missing", 404, ""},
+		{"/items/" + url.PathEscape(items[3].ID), 200, "output of run 3"},
+		{"/items/missing", 404, ""},
+	} {
+		res := httptest.NewRecorder()
+		p.ServeHTTP(res, httptest.NewRequest("GET", c.path, nil))
+		if res.Code != c.status {
+			t.Error(fmt.Errorf("Expected %s to return %d, got: %d", c.path, c.status, res.Code))
+			continue
+		}
+		if !strings.Contains(res.Body.String(), c.contains) {
+			t.Error(fmt.Errorf("Expected %s to contain '%s'", c.path, c.contains))
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	removeTestDB("server-events-test.db")
+	defer removeTestDB("server-events-test.db")
+	historyFile, err := history.New(history.NewOptions{
+		File: "server-events-test.db",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer historyFile.Close()
+
+	p, err := New(CreatePatrolOptions{
+		Checkers: []*checker.Checker{
+			checker.New(&checker.Checker{
+				Group:    "other",
+				Name:     "bar",
+				Cmd:      "true",
+				History:  historyFile,
+				Interval: 1 * time.Minute,
+			}),
+			checker.New(&checker.Checker{
+				Group:    "foo",
+				Name:     "bar",
+				Cmd:      "true",
+				History:  historyFile,
+				Interval: 1 * time.Minute,
+			}),
+		},
+	}, historyFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	server := httptest.NewServer(p.server.Handler)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/api/v1/events?group=foo")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer res.Body.Close()
+	if contentType := res.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Error(fmt.Errorf("Unexpected content type: %s", contentType))
+		return
+	}
+
+	for _, status := range []string{"healthy", "unhealthy"} {
+		for _, group := range []string{"other", "foo"} {
+			item, err := historyFile.Append(history.Item{Group: group, Name: "bar", Type: "boolean", Status: status})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			p.OnCheckerItem(item)
+			p.OnCheckerStatus(item.Status, item.Group, item.Name)
+		}
+	}
+
+	// Reads events until the given number of events has been received
+	readEvents := func(body *bufio.Reader, n int) (types, ids []string) {
+		for len(types) < n {
+			line, err := body.ReadString('\n')
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if strings.HasPrefix(line, "event: ") {
+				types = append(types, strings.TrimSpace(strings.TrimPrefix(line, "event: ")))
+			} else if strings.HasPrefix(line, "id: ") {
+				ids = append(ids, strings.TrimSpace(strings.TrimPrefix(line, "id: ")))
+			}
+		}
+		return
+	}
+
+	types, ids := readEvents(bufio.NewReader(res.Body), 3)
+	if strings.Join(types, ",") != "item,item,status" {
+		t.Error(fmt.Errorf("Unexpected events: %v", types))
+		return
+	}
+
+	// Resuming from the first event should replay the events that followed
+	req, err := http.NewRequest("GET", server.URL+"/api/v1/events?group=foo", nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	req.Header.Set("Last-Event-ID", ids[0])
+	resumed, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer resumed.Body.Close()
+
+	types, replayedIDs := readEvents(bufio.NewReader(resumed.Body), 2)
+	if strings.Join(types, ",") != "item,status" || replayedIDs[0] != ids[1] {
+		t.Error(fmt.Errorf("Unexpected replayed events: %v (%v)", types, replayedIDs))
+		return
+	}
+}
+
+func TestBadges(t *testing.T) {
+	removeTestDB("server-badge-test.db")
+	defer removeTestDB("server-badge-test.db")
+	historyFile, err := history.New(history.NewOptions{
+		File: "server-badge-test.db",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer historyFile.Close()
+
+	p, err := New(CreatePatrolOptions{
+		Checkers: []*checker.Checker{
+			{Group: "web", Name: "API status", Type: "boolean", Interval: time.Minute},
+			{Group: "web", Name: "Latency", Type: "metric", MetricUnit: "ms", Interval: 30 * time.Second},
+		},
+	}, historyFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, item := range []history.Item{
+		{Group: "web", Name: "API status", Type: "boolean", Status: "unhealthy"},
+		{Group: "web", Name: "Latency", Type: "metric", Status: "healthy", Metric: 12.5, MetricUnit: "ms"},
+	} {
+		if _, err := historyFile.Append(item); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	var etag string
+	for _, c := range []struct {
+		path     string
+		status   int
+		contains string
+	}{
+		{"/badge/web.svg", 200, ">unhealthy<"},
+		{"/badge/web/Latency.svg", 200, ">healthy<"},
+		{"/badge/web/Latency.svg?type=metric", 200, ">12.50 ms<"},
+		{"/badge/web/Latency.svg?type=uptime&window=1h&label=up", 200, ">up<"},
+		{"/badge/web.svg?type=metric", 400, ""},
+		{"/badge/web.svg?type=uptime&window=bad", 400, ""},
+		{"/badge/missing.svg", 404, ""},
+	} {
+		res := httptest.NewRecorder()
+		p.ServeHTTP(res, httptest.NewRequest("GET", c.path, nil))
+		if res.Code != c.status {
+			t.Error(fmt.Errorf("Expected %s to return %d, got: %d", c.path, c.status, res.Code))
+			continue
+		}
+		if !strings.Contains(res.Body.String(), c.contains) {
+			t.Error(fmt.Errorf("Expected %s to contain '%s': %s", c.path, c.contains, res.Body.String()))
+		}
+		if c.path == "/badge/web.svg" {
+			etag = res.Header().Get("ETag")
+			if cacheControl := res.Header().Get("Cache-Control"); cacheControl != "public, max-age=30" {
+				t.Error(fmt.Errorf("Unexpected cache control: %s", cacheControl))
+			}
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/badge/web.svg", nil)
+	req.Header.Set("If-None-Match", etag)
+	res := httptest.NewRecorder()
+	p.ServeHTTP(res, req)
+	if res.Code != http.StatusNotModified {
+		t.Error(fmt.Errorf("Expected unchanged badge to return 304, got: %d", res.Code))
+	}
+}
+
+func TestFeeds(t *testing.T) {
+	removeTestDB("server-feed-test.db")
+	defer removeTestDB("server-feed-test.db")
+	historyFile, err := history.New(history.NewOptions{
+		File: "server-feed-test.db",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer historyFile.Close()
+
+	p, err := New(CreatePatrolOptions{
+		Checkers: []*checker.Checker{
+			{Group: "web", Name: "API status", Type: "metric"},
+			{Group: "db", Name: "Replication", Type: "metric"},
+		},
+	}, historyFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, item := range []history.Item{
+		{Group: "web", Name: "API status", Type: "metric", Status: "healthy"},
+		{Group: "web", Name: "API status", Type: "metric", Status: "unhealthy", Error: "Process exited with status 7"},
+		{Group: "web", Name: "API status", Type: "metric", Status: "healthy"},
+		{Group: "db", Name: "Replication", Type: "metric", Status: "unhealthy"},
+	} {
+		item, err := historyFile.Append(item)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		p.OnCheckerItem(item)
+	}
+
+	for _, c := range []struct {
+		path        string
+		contentType string
+		contains    []string
+		excludes    []string
+	}{
+		{"/feed.atom", "application/atom+xml; charset=utf-8", []string{
+			"web / API status is unhealthy",
+			"web / API status is healthy",
+			"Process exited with status 7",
+			"Incident: web / API status (resolved)",
+			"Incident: db / Replication",
+		}, nil},
+		{"/feed.rss?group=web", "application/rss+xml; charset=utf-8", []string{
+			"<rss version=\"2.0\">",
+			"web / API status is unhealthy",
+		}, []string{"db / Replication"}},
+	} {
+		res := httptest.NewRecorder()
+		p.ServeHTTP(res, httptest.NewRequest("GET", c.path, nil))
+		if res.Code != 200 || res.Header().Get("Content-Type") != c.contentType {
+			t.Error(fmt.Errorf("Unexpected response from %s: %d (%s)", c.path, res.Code, res.Header().Get("Content-Type")))
+			continue
+		}
+		for _, str := range c.contains {
+			if !strings.Contains(res.Body.String(), str) {
+				t.Error(fmt.Errorf("Expected %s to contain '%s': %s", c.path, str, res.Body.String()))
+			}
+		}
+		for _, str := range c.excludes {
+			if strings.Contains(res.Body.String(), str) {
+				t.Error(fmt.Errorf("Expected %s not to contain '%s'", c.path, str))
+			}
+		}
+	}
+
+	res := httptest.NewRecorder()
+	p.ServeHTTP(res, httptest.NewRequest("GET", "/feed.atom?group=missing", nil))
+	if res.Code != 404 {
+		t.Error(fmt.Errorf("Expected feed of unknown group to return 404, got: %d", res.Code))
+	}
+}
+
+func TestAuth(t *testing.T) {
+	removeTestDB("server-auth-test.db")
+	defer removeTestDB("server-auth-test.db")
+	historyFile, err := history.New(history.NewOptions{
+		File: "server-auth-test.db",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer historyFile.Close()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	p, err := New(CreatePatrolOptions{
+		Checkers: []*checker.Checker{},
+		Auth: auth.Options{
+			Users: []auth.User{{Username: "alice", PasswordHash: string(hash), Scope: auth.ScopeRead}},
+			Tokens: []auth.Token{
+				{Name: "reader", Token: "read-token", Scope: auth.ScopeRead},
+				{Name: "writer", Token: "write-token", Scope: auth.ScopeWrite},
+				{Name: "admin", Token: "admin-token", Scope: auth.ScopeAdmin},
+			},
+		},
+		APIToken: "api-token",
+	}, historyFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	request := func(method, path, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token == "alice" {
+			req.SetBasicAuth("alice", "hunter2")
+		} else if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res := httptest.NewRecorder()
+		p.ServeHTTP(res, req)
+		return res
+	}
+
+	res := request("GET", "/", "", "")
+	if res.Code != http.StatusUnauthorized || res.Header().Get("WWW-Authenticate") == "" {
+		t.Error(fmt.Errorf("Expected anonymous request to be asked for credentials, got: %d", res.Code))
+	}
+	for _, c := range []struct {
+		method, path, body, token string
+		status                    int
+	}{
+		{"GET", "/", "", "alice", http.StatusOK},
+		{"GET", "/api/v1/silences", "", "read-token", http.StatusOK},
+		{"GET", "/api/v1/silences", "", "wrong-token", http.StatusUnauthorized},
+		{"POST", "/api/v1/announcements", `{"title":"Outage"}`, "read-token", http.StatusForbidden},
+		{"POST", "/api/v1/announcements", `{"title":"Outage"}`, "write-token", http.StatusCreated},
+		{"DELETE", "/api/v1/announcements/missing", "", "write-token", http.StatusForbidden},
+		{"DELETE", "/api/v1/announcements/missing", "", "admin-token", http.StatusNotFound},
+		{"POST", "/api/v1/announcements", `{"title":"Outage"}`, "api-token", http.StatusCreated},
+		{"DELETE", "/api/v1/announcements/missing", "", "api-token", http.StatusNotFound},
+	} {
+		if res := request(c.method, c.path, c.body, c.token); res.Code != c.status {
+			t.Error(fmt.Errorf("Expected %s %s with %s to return %d, got: %d", c.method, c.path, c.token, c.status, res.Code))
+		}
+	}
+
+	// Configs that only set the legacy 'apiToken' keep a public status page
+	// unless 'anonymous' says otherwise
+	for _, c := range []struct {
+		anonymous *auth.Scope
+		status    int
+	}{
+		{nil, http.StatusOK},
+		{new(auth.Scope), http.StatusUnauthorized},
+	} {
+		legacy, err := New(CreatePatrolOptions{
+			Checkers: []*checker.Checker{},
+			Auth:     auth.Options{Anonymous: c.anonymous},
+			APIToken: "api-token",
+		}, historyFile)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		res := httptest.NewRecorder()
+		legacy.ServeHTTP(res, httptest.NewRequest("GET", "/", nil))
+		if res.Code != c.status {
+			t.Error(fmt.Errorf("Expected anonymous request with only apiToken (anonymous: %v) to return %d, got: %d", c.anonymous, c.status, res.Code))
+		}
+	}
+}
+
+func TestVisibility(t *testing.T) {
+	removeTestDB("server-visibility-test.db")
+	defer removeTestDB("server-visibility-test.db")
+	historyFile, err := history.New(history.NewOptions{
+		File: "server-visibility-test.db",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer historyFile.Close()
+
+	p, err := New(CreatePatrolOptions{
+		Checkers: []*checker.Checker{
+			checker.New(&checker.Checker{
+				Group:    "web",
+				Name:     "Homepage",
+				Cmd:      "true",
+				History:  historyFile,
+				Interval: 1 * time.Minute,
+			}),
+			checker.New(&checker.Checker{
+				Group:      "web",
+				Name:       "Database",
+				Cmd:        "true",
+				History:    historyFile,
+				Interval:   1 * time.Minute,
+				HideOutput: true,
+			}),
+			checker.New(&checker.Checker{
+				Group:      "internal",
+				Name:       "Queue depth",
+				Cmd:        "true",
+				History:    historyFile,
+				Interval:   1 * time.Minute,
+				Visibility: "internal",
+				Labels:     map[string]string{"team": "queue-team"},
+			}),
+		},
+		APIToken: "secret",
+	}, historyFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var queueItem history.Item
+	for _, item := range []history.Item{
+		{Group: "web", Name: "Homepage", Type: "boolean", Status: "healthy", Output: []byte("homepage output")},
+		{Group: "web", Name: "Database", Type: "boolean", Status: "unhealthy", Output: []byte("connection string"), Error: "database error"},
+		{Group: "web", Name: "Removed check", Type: "boolean", Status: "unhealthy", Output: []byte("removed output")},
+		{Group: "internal", Name: "Queue depth", Type: "boolean", Status: "unhealthy", Output: []byte("queue output")},
+	} {
+		if queueItem, err = historyFile.Append(item); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	internalAnnouncement, err := p.announcements.Create("Queue is backed up", []string{"internal"}, "", "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	mixedAnnouncement, err := p.announcements.Create("Slow responses", []string{"web", "internal"}, "", "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, s := range []silence.Silence{
+		{Group: "internal", Comment: "Draining the queue", EndsAt: time.Now().Add(time.Hour)},
+		{Labels: map[string]string{"team": "queue-team"}, Comment: "Queue team is away", EndsAt: time.Now().Add(time.Hour)},
+	} {
+		if _, err := p.silences.Add(s); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	request := func(path, token string) string {
+		req := httptest.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res := httptest.NewRecorder()
+		p.ServeHTTP(res, req)
+		if res.Code != http.StatusOK {
+			return fmt.Sprintf("status %d", res.Code)
+		}
+		return res.Body.String()
+	}
+
+	public := request("/", "")
+	for _, hidden := range []string{"Queue depth", "database error", "connection string", "Removed check", "removed output", `data-action="`} {
+		if strings.Contains(public, hidden) {
+			t.Error(fmt.Errorf("Expected public status page to hide '%s'", hidden))
+		}
+	}
+	if !strings.Contains(public, "Homepage") {
+		t.Error(fmt.Errorf("Expected public status page to show public checks"))
+	}
+	private := request("/", "secret")
+	for _, shown := range []string{"Queue depth", "database error", "connection string", "removed output", `data-action="acknowledge"`, `data-action="unsilence"`, `data-action="silence"`} {
+		if !strings.Contains(private, shown) {
+			t.Error(fmt.Errorf("Expected authenticated status page to show '%s'", shown))
+		}
+	}
+
+	for _, c := range []struct {
+		path, token, expected string
+	}{
+		{"/groups/internal/checks/Queue%20depth", "", "status 404"},
+		{"/items/" + url.PathEscape(queueItem.ID), "", "status 404"},
+		{"/items/" + url.PathEscape(queueItem.ID), "secret", "queue output"},
+		{"/badge/internal.svg", "", "status 404"},
+		{"/groups/web/checks/Removed%20check", "", "status 404"},
+		{"/feed.atom?group=internal", "", "status 404"},
+		{"/api/v1/uptime", "secret", "Queue depth"},
+		{"/api/v1/announcements/" + internalAnnouncement.ID, "", "status 404"},
+		{"/api/v1/announcements/" + internalAnnouncement.ID, "secret", "Queue is backed up"},
+		{"/api/v1/announcements/" + mixedAnnouncement.ID, "", `"Services":["web"]`},
+		{"/api/v1/silences", "secret", "Queue team is away"},
+	} {
+		if body := request(c.path, c.token); !strings.Contains(body, c.expected) {
+			t.Error(fmt.Errorf("Expected %s to contain '%s', got: %s", c.path, c.expected, body))
+		}
+	}
+	for _, path := range []string{"/", "/api/v1/uptime", "/api/v1/incidents", "/api/v1/announcements", "/api/v1/silences", "/feed.atom"} {
+		if body := request(path, ""); strings.Contains(body, "Queue") || strings.Contains(body, "internal") || strings.Contains(body, "database error") {
+			t.Error(fmt.Errorf("Expected %s to hide internal details, got: %s", path, body))
+		}
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	removeTestDB("server-escaping-test.db")
+	defer removeTestDB("server-escaping-test.db")
+	historyFile, err := history.New(history.NewOptions{
+		File: "server-escaping-test.db",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer historyFile.Close()
+
+	const payload = `<script>alert("xss")</script>`
+	group := `"><script>alert("group")</script>`
+	p, err := New(CreatePatrolOptions{
+		Checkers: []*checker.Checker{
+			checker.New(&checker.Checker{
+				Group:    group,
+				Name:     "Boolean " + payload,
+				Cmd:      "true",
+				History:  historyFile,
+				Interval: 1 * time.Minute,
+			}),
+			checker.New(&checker.Checker{
+				Group:    group,
+				Name:     "Metric",
+				Type:     "metric",
+				Cmd:      "true",
+				History:  historyFile,
+				Interval: 1 * time.Minute,
+			}),
+		},
+	}, historyFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var items []history.Item
+	for _, item := range []history.Item{
+		{Group: group, Name: "Boolean " + payload, Type: "boolean", Status: "unhealthy", Output: []byte(payload), Error: payload},
+		{Group: group, Name: "Metric", Type: "metric", Status: "unhealthy", Metric: 10, MetricUnit: payload, Output: []byte(payload), Error: payload},
+	} {
+		item, err = historyFile.Append(item)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		items = append(items, item)
+	}
+
+	for _, path := range []string{
+		"/",
+		"/?group=" + url.QueryEscape(group),
+		"/groups/" + url.PathEscape(group) + "/checks/" + url.PathEscape(items[0].Name),
+		"/items/" + url.PathEscape(items[1].ID),
+	} {
+		res := httptest.NewRecorder()
+		p.ServeHTTP(res, httptest.NewRequest("GET", path, nil))
+		body := res.Body.String()
+		if res.Code != http.StatusOK {
+			t.Error(fmt.Errorf("Expected %s to return 200, got: %d\n%s", path, res.Code, body))
+			continue
+		}
+		if strings.Contains(body, "<script>alert") {
+			t.Error(fmt.Errorf("Expected %s to escape script payloads, got: %s", path, body))
+		}
+		if !strings.Contains(body, "&lt;script&gt;alert") {
+			t.Error(fmt.Errorf("Expected %s to contain escaped payloads", path))
+		}
+	}
+
+	res := httptest.NewRecorder()
+	p.ServeHTTP(res, httptest.NewRequest("GET", "/", nil))
+	body := res.Body.String()
+	if !strings.Contains(body, `src="/charts/%22%3E%3Cscript%3Ealert%28%22group%22%29%3C%2Fscript%3E/Metric.svg"`) {
+		t.Error(fmt.Errorf("Expected metric chart to be linked, got: %s", body))
+	}
+	if !strings.Contains(body, `href="/?group=%22%3e%3cscript%3ealert%28%22group%22%29%3c%2fscript%3e"`) {
+		t.Error(fmt.Errorf("Expected focus link to escape the group name, got: %s", body))
+	}
+	if strings.Contains(body, "ZgotmplZ") {
+		t.Error(fmt.Errorf("Expected no unsafe values to be filtered out of the page"))
+	}
+}
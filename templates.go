@@ -0,0 +1,250 @@
+package patrol
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/karimsa/patrol/internal/announcement"
+	"github.com/karimsa/patrol/internal/history"
+	"github.com/karimsa/patrol/internal/incident"
+	"github.com/karimsa/patrol/internal/silence"
+)
+
+// Branding options used to customize the look of the status page.
+type Branding struct {
+	// URLs of images shown next to the name of the status page, and used
+	// as the icon of the page.
+	Logo    string
+	Favicon string
+
+	// Colors of the page header, and of buttons and links. Must be given
+	// as hex codes, such as '#2b6cb0'.
+	HeaderColor string `yaml:"headerColor"`
+	AccentColor string `yaml:"accentColor"`
+
+	// Links shown at the bottom of every page.
+	FooterLinks []FooterLink `yaml:"footerLinks"`
+
+	// Stylesheet added to every page, after the default styles.
+	CSS string `yaml:"css"`
+}
+
+// FooterLink is a link shown at the bottom of every page.
+type FooterLink struct {
+	Title string
+	URL   string
+}
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// Only absolute paths and http(s) URLs are allowed, since other schemes
+// would be filtered out when rendering anyway.
+func validateLink(link string) error {
+	u, err := url.Parse(link)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" && u.Host == "" && strings.HasPrefix(u.Path, "/") {
+		return nil
+	}
+	if (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		return nil
+	}
+	return fmt.Errorf("'%s' must be an absolute path or an http(s) URL", link)
+}
+
+func (b Branding) validate() error {
+	for name, link := range map[string]string{"logo": b.Logo, "favicon": b.Favicon} {
+		if link != "" {
+			if err := validateLink(link); err != nil {
+				return fmt.Errorf("Invalid %s: %s", name, err)
+			}
+		}
+	}
+	for name, color := range map[string]string{"header color": b.HeaderColor, "accent color": b.AccentColor} {
+		if color != "" && !hexColor.MatchString(color) {
+			return fmt.Errorf("Invalid %s '%s', must be a hex color such as '#2b6cb0'", name, color)
+		}
+	}
+	for idx, link := range b.FooterLinks {
+		if link.Title == "" {
+			return fmt.Errorf("Footer link %d is missing a title", idx+1)
+		}
+		if err := validateLink(link.URL); err != nil {
+			return fmt.Errorf("Invalid URL for footer link '%s': %s", link.Title, err)
+		}
+	}
+	if strings.Contains(strings.ToLower(b.CSS), "</style") {
+		return fmt.Errorf("Custom CSS cannot close the <style> element")
+	}
+	return nil
+}
+
+// Styles returns the stylesheet for the configured colors and custom CSS.
+func (b Branding) Styles() template.CSS {
+	css := strings.Builder{}
+	if b.HeaderColor != "" {
+		fmt.Fprintf(&css, ".brand-header{background-color:%s}", b.HeaderColor)
+	}
+	if b.AccentColor != "" {
+		fmt.Fprintf(&css, ".brand-accent{background-color:%s}.brand-accent-text{color:%s}", b.AccentColor, b.AccentColor)
+	}
+	css.WriteString(b.CSS)
+	return template.CSS(css.String())
+}
+
+// Templates used to render each page of the web interface.
+type pageTemplates struct {
+	index, incidents, check *template.Template
+}
+
+// Loads the embedded templates, replacing or extending them with the
+// templates found in the given directory (if any).
+//
+// Pages are replaced by files with the same name ('index.html',
+// 'incidents.html', and 'check.html') and 'styles.css' replaces the default
+// stylesheet. Any other '*.html' file is parsed along with every page, so it
+// can redefine the embedded partials ('head', 'logo', and 'footer') or add
+// new ones.
+func loadTemplates(dir string) (*pageTemplates, error) {
+	sources := map[string]string{
+		"index.html":     indexHTML,
+		"incidents.html": incidentsHTML,
+		"check.html":     checkHTML,
+	}
+	styles := stylesCSS
+	var partials []string
+
+	if dir != "" {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read template directory: %s", err)
+		}
+		for _, file := range files {
+			name := file.Name()
+			if file.IsDir() || (filepath.Ext(name) != ".html" && name != "styles.css") {
+				continue
+			}
+			buffer, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				return nil, fmt.Errorf("Failed to read template: %s", err)
+			}
+			if name == "styles.css" {
+				styles = string(buffer)
+			} else if _, ok := sources[name]; ok {
+				sources[name] = string(buffer)
+			} else {
+				partials = append(partials, name)
+				sources[name] = string(buffer)
+			}
+		}
+	}
+
+	funcs := make(template.FuncMap, len(templateFuncs)+1)
+	for name, fn := range templateFuncs {
+		funcs[name] = fn
+	}
+	funcs["styles"] = func() template.CSS {
+		return template.CSS(styles)
+	}
+
+	parse := func(page string) (*template.Template, error) {
+		tmpl := template.New(page).Funcs(funcs)
+		if _, err := tmpl.New("partials.html").Parse(partialsHTML); err != nil {
+			return nil, fmt.Errorf("Failed to parse embedded partials: %s", err)
+		}
+		if _, err := tmpl.Parse(sources[page]); err != nil {
+			return nil, fmt.Errorf("Failed to parse %s: %s", page, err)
+		}
+		for _, name := range partials {
+			if _, err := tmpl.New(name).Parse(sources[name]); err != nil {
+				return nil, fmt.Errorf("Failed to parse %s: %s", name, err)
+			}
+		}
+		return tmpl, nil
+	}
+
+	var views pageTemplates
+	var err error
+	if views.index, err = parse("index.html"); err != nil {
+		return nil, err
+	}
+	if views.incidents, err = parse("incidents.html"); err != nil {
+		return nil, err
+	}
+	if views.check, err = parse("check.html"); err != nil {
+		return nil, err
+	}
+	return &views, nil
+}
+
+// Renders every page with sample data, so that templates referring to
+// fields that do not exist are caught at startup instead of when the page is
+// first visited.
+func (views *pageTemplates) validate(branding Branding) error {
+	now := time.Now()
+	boolItem := history.Item{ID: "1", Group: "Example", Name: "Boolean", Type: "boolean", Status: "unhealthy", Output: []byte("output"), Error: "error", CreatedAt: now}
+	metricItem := history.Item{ID: "2", Group: "Example", Name: "Metric", Type: "metric", Status: "healthy", Metric: 1, MetricUnit: "ms", CreatedAt: now}
+	sampleIncident := incident.Incident{ID: "1", Group: "Example", Check: "Boolean", Start: now, FirstError: "error"}
+
+	for _, sample := range []struct {
+		name string
+		tmpl *template.Template
+		data interface{}
+	}{
+		{"index.html", views.index, indexPage{
+			Name:     "Statuspage",
+			Branding: branding,
+			Groups: map[string]map[string][]history.Item{
+				"Example": {"Boolean": {boolItem}, "Metric": {metricItem}},
+			},
+			OrderedGroups: []GroupData{{
+				Name:   "Example",
+				Checks: []CheckData{{Name: "Boolean", Items: []history.Item{boolItem}}, {Name: "Metric", Items: []history.Item{metricItem}}},
+			}},
+			NumServicesDown: 1,
+			NumServices:     2,
+			LatestCreatedAt: now,
+			Silenced:        map[string]*silence.Silence{},
+			Acknowledged:    map[string]bool{},
+			Maintenance:     []maintenanceNotice{{Group: "Example", Check: "Boolean", Until: now}},
+			Announcements: []announcement.Announcement{{
+				ID:      "1",
+				Title:   "Announcement",
+				Status:  "investigating",
+				Updates: []announcement.Update{{Status: "investigating", Message: "message", CreatedAt: now}},
+			}},
+		}},
+		{"incidents.html", views.incidents, incidentsPage{Name: "Statuspage", Branding: branding, Incidents: []incident.Incident{sampleIncident}}},
+		{"incidents.html", views.incidents, incidentsPage{Name: "Statuspage", Branding: branding, Incident: &sampleIncident, Items: []history.Item{boolItem}}},
+		{"check.html", views.check, checkPage{Name: "Statuspage", Branding: branding, Group: "Example", Check: "Boolean", Items: []history.Item{boolItem}, Page: 1, NumPages: 1, NumItems: 1}},
+		{"check.html", views.check, checkPage{Name: "Statuspage", Branding: branding, Group: "Example", Check: "Boolean", Item: &boolItem}},
+	} {
+		if err := sample.tmpl.Execute(ioutil.Discard, sample.data); err != nil {
+			return fmt.Errorf("Template %s failed to render: %s", sample.name, err)
+		}
+	}
+	return nil
+}
+
+// Renders the template into a buffer first, so that a failing template does
+// not leave a partially written page.
+func (p *Patrol) render(res http.ResponseWriter, tmpl *template.Template, data interface{}) {
+	buffer := bytes.Buffer{}
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		p.logger.Warnf("Failed to execute template: %s", err)
+		res.WriteHeader(500)
+		res.Write([]byte(err.Error()))
+		return
+	}
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.Write(buffer.Bytes())
+}
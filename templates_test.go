@@ -0,0 +1,172 @@
+package patrol
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/karimsa/patrol/internal/checker"
+	"github.com/karimsa/patrol/internal/history"
+)
+
+func TestBranding(t *testing.T) {
+	removeTestDB("templates-branding-test.db")
+	defer removeTestDB("templates-branding-test.db")
+	historyFile, err := history.New(history.NewOptions{
+		File: "templates-branding-test.db",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer historyFile.Close()
+
+	for _, branding := range []Branding{
+		{Logo: "javascript:alert(1)"},
+		{Favicon: "favicon.ico"},
+		{AccentColor: "red;}body{display:none"},
+		{FooterLinks: []FooterLink{{URL: "https://example.com"}}},
+		{CSS: "</style><script>alert(1)</script>"},
+	} {
+		if _, err := New(CreatePatrolOptions{Checkers: []*checker.Checker{}, Branding: branding}, historyFile); err == nil {
+			t.Error(fmt.Errorf("Expected invalid branding to be rejected: %#v", branding))
+		}
+	}
+
+	p, err := New(CreatePatrolOptions{
+		Checkers: []*checker.Checker{},
+		Branding: Branding{
+			Logo:        "https://example.com/logo.png",
+			Favicon:     "/favicon.ico",
+			HeaderColor: "#123456",
+			AccentColor: "#abcdef",
+			FooterLinks: []FooterLink{{Title: "Contact us", URL: "https://example.com/contact"}},
+			CSS:         ".custom-rule{color:red}",
+		},
+	}, historyFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, path := range []string{"/", "/incidents"} {
+		res := httptest.NewRecorder()
+		p.ServeHTTP(res, httptest.NewRequest("GET", path, nil))
+		body := res.Body.String()
+		for _, expected := range []string{
+			`src="https://example.com/logo.png"`,
+			`href="/favicon.ico"`,
+			`.brand-header{background-color:#123456}`,
+			`.brand-accent{background-color:#abcdef}`,
+			`.custom-rule{color:red}`,
+			`href="https://example.com/contact"`,
+			`Contact us`,
+		} {
+			if !strings.Contains(body, expected) {
+				t.Error(fmt.Errorf("Expected %s to contain '%s'", path, expected))
+			}
+		}
+	}
+}
+
+func TestTemplateDir(t *testing.T) {
+	removeTestDB("templates-dir-test.db")
+	defer removeTestDB("templates-dir-test.db")
+	historyFile, err := history.New(history.NewOptions{
+		File: "templates-dir-test.db",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer historyFile.Close()
+
+	dir, err := ioutil.TempDir("", "patrol-templates")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("footer.html", `{{define "footer"}}<footer>Custom footer for {{.Name}}</footer>{{end}}`)
+	writeFile("styles.css", `.custom-stylesheet{}`)
+
+	p, err := New(CreatePatrolOptions{
+		Name:        "Acme",
+		Checkers:    []*checker.Checker{},
+		TemplateDir: dir,
+	}, historyFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	res := httptest.NewRecorder()
+	p.ServeHTTP(res, httptest.NewRequest("GET", "/incidents", nil))
+	if body := res.Body.String(); !strings.Contains(body, "Custom footer for Acme") || !strings.Contains(body, ".custom-stylesheet{}") {
+		t.Error(fmt.Errorf("Expected partials and styles to be overridden, got: %s", body))
+	}
+
+	writeFile("index.html", `<!doctype html><title>{{.Name}}</title><p>{{len .Groups}} services</p>`)
+	p, err = New(CreatePatrolOptions{
+		Name:        "Acme",
+		Checkers:    []*checker.Checker{},
+		TemplateDir: dir,
+	}, historyFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	res = httptest.NewRecorder()
+	p.ServeHTTP(res, httptest.NewRequest("GET", "/", nil))
+	if body := res.Body.String(); body != `<!doctype html><title>Acme</title><p>0 services</p>` {
+		t.Error(fmt.Errorf("Expected index page to be replaced, got: %s", body))
+	}
+
+	writeFile("index.html", `{{.Missing}}`)
+	if _, err := New(CreatePatrolOptions{Checkers: []*checker.Checker{}, TemplateDir: dir}, historyFile); err == nil || !strings.Contains(err.Error(), "index.html") {
+		t.Error(fmt.Errorf("Expected template referring to missing fields to be rejected, got: %v", err))
+	}
+	writeFile("index.html", `{{if}}`)
+	if _, err := New(CreatePatrolOptions{Checkers: []*checker.Checker{}, TemplateDir: dir}, historyFile); err == nil || !strings.Contains(err.Error(), "index.html") {
+		t.Error(fmt.Errorf("Expected template with syntax errors to be rejected, got: %v", err))
+	}
+	if _, err := New(CreatePatrolOptions{Checkers: []*checker.Checker{}, TemplateDir: filepath.Join(dir, "missing")}, historyFile); err == nil {
+		t.Error(fmt.Errorf("Expected missing template directory to be rejected"))
+	}
+}
+
+// Templates are minified onto a single line, so a '//' comment in a script
+// would comment out the rest of it.
+func TestScriptComments(t *testing.T) {
+	files, err := filepath.Glob("*.html")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		inScript := false
+		for idx, line := range strings.Split(string(data), "\n") {
+			if strings.Contains(line, "<script") && !strings.Contains(line, "</script>") {
+				inScript = true
+			} else if strings.Contains(line, "</script>") {
+				inScript = false
+			} else if inScript && strings.HasPrefix(strings.TrimSpace(line), "//") {
+				t.Error(fmt.Errorf("%s:%d: scripts must use /* */ comments", file, idx+1))
+			}
+		}
+	}
+}
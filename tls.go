@@ -0,0 +1,164 @@
+package patrol
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/karimsa/patrol/internal/logger"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// How often the certificate and key files are checked for changes, so that
+// handshakes do not stat them every time.
+var certCheckInterval = 5 * time.Second
+
+// Keeps the certificate used by the HTTPS server, and loads it again
+// whenever the certificate or key file changes on disk.
+type certReloader struct {
+	certFile, keyFile string
+	logger            logger.Logger
+
+	mux       sync.Mutex
+	cert      *tls.Certificate
+	modTimes  [2]time.Time
+	checkedAt time.Time
+}
+
+// Returns the modification times of the certificate and key files.
+func (r *certReloader) stat() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+	for idx, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[idx] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// Loads the certificate if either file changed since it was last loaded.
+func (r *certReloader) reload() error {
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.cert != nil && modTimes == r.modTimes {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	if r.cert != nil {
+		r.logger.Infof("Reloaded TLS certificate from %s", r.certFile)
+	}
+	r.cert = &cert
+	r.modTimes = modTimes
+	return nil
+}
+
+// Used as 'GetCertificate' of the TLS config. The files are checked for
+// changes at most once every 'certCheckInterval'. If they changed but cannot
+// be loaded (for instance, while only one of them has been replaced), the
+// previous certificate keeps being used.
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mux.Lock()
+	due := time.Since(r.checkedAt) >= certCheckInterval
+	if due {
+		r.checkedAt = time.Now()
+	}
+	r.mux.Unlock()
+	if due {
+		if err := r.reload(); err != nil {
+			r.logger.Warnf("Failed to reload TLS certificate: %s", err)
+		}
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.cert == nil {
+		return nil, fmt.Errorf("No TLS certificate loaded from %s", r.certFile)
+	}
+	return r.cert, nil
+}
+
+// Returns the TLS config of the HTTPS server.
+func (options *PatrolHttpsOptions) tlsConfig(log logger.Logger) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if options.MinVersion != "" {
+		version, ok := tlsVersions[options.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("Invalid minimum TLS version '%s': must be one of 1.0, 1.1, 1.2, 1.3", options.MinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if len(options.CipherSuites) > 0 {
+		suites := make(map[string]uint16)
+		for _, suite := range tls.CipherSuites() {
+			suites[suite.Name] = suite.ID
+		}
+		for _, name := range options.CipherSuites {
+			id, ok := suites[name]
+			if !ok {
+				return nil, fmt.Errorf("Unknown or insecure cipher suite: %s", name)
+			}
+			config.CipherSuites = append(config.CipherSuites, id)
+		}
+	}
+
+	if options.ClientCA != "" {
+		bundle, err := ioutil.ReadFile(options.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read client CA bundle: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("No certificates found in client CA bundle: %s", options.ClientCA)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	reloader := &certReloader{
+		certFile: options.Cert,
+		keyFile:  options.Key,
+		logger:   log,
+	}
+	if options.Cert != "" || options.Key != "" {
+		if err := reloader.reload(); err != nil {
+			return nil, fmt.Errorf("Failed to load TLS certificate: %s", err)
+		}
+	}
+	config.GetCertificate = reloader.getCertificate
+	return config, nil
+}
+
+// Sets the 'Strict-Transport-Security' header, if enabled.
+func (p *Patrol) writeHSTS(res http.ResponseWriter) {
+	if p.https == nil || p.https.HSTS <= 0 {
+		return
+	}
+	value := []string{fmt.Sprintf("max-age=%d", int64(p.https.HSTS.Seconds()))}
+	if p.https.HSTSIncludeSubdomains {
+		value = append(value, "includeSubDomains")
+	}
+	res.Header().Set("Strict-Transport-Security", strings.Join(value, "; "))
+}
@@ -0,0 +1,171 @@
+package patrol
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/karimsa/patrol/internal/checker"
+	"github.com/karimsa/patrol/internal/history"
+)
+
+// Writes a self-signed certificate for the given name, and its key.
+func writeCert(dir, name string) (certFile, keyFile string, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return
+	}
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		return
+	}
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return
+}
+
+func TestTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "patrol-tls")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	removeTestDB("tls-test.db")
+	defer removeTestDB("tls-test.db")
+	historyFile, err := history.New(history.NewOptions{
+		File: "tls-test.db",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer historyFile.Close()
+
+	certFile, keyFile, err := writeCert(dir, "first.example.com")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, options := range []PatrolHttpsOptions{
+		{Cert: certFile, Key: filepath.Join(dir, "missing.pem"), Port: 8443},
+		{Cert: certFile, Key: keyFile, Port: 8443, MinVersion: "1.4"},
+		{Cert: certFile, Key: keyFile, Port: 8443, CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+		{Cert: certFile, Key: keyFile, Port: 8443, ClientCA: keyFile},
+	} {
+		options := options
+		if _, err := New(CreatePatrolOptions{Checkers: []*checker.Checker{}, HTTPS: &options}, historyFile); err == nil {
+			t.Error(fmt.Errorf("Expected invalid HTTPS options to be rejected: %#v", options))
+		}
+	}
+
+	p, err := New(CreatePatrolOptions{
+		Checkers: []*checker.Checker{},
+		HTTPS: &PatrolHttpsOptions{
+			Cert:                  certFile,
+			Key:                   keyFile,
+			Port:                  8443,
+			MinVersion:            "1.3",
+			CipherSuites:          []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+			ClientCA:              certFile,
+			HSTS:                  365 * 24 * time.Hour,
+			HSTSIncludeSubdomains: true,
+		},
+	}, historyFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	config := p.server.TLSConfig
+	if config.MinVersion != tls.VersionTLS13 || len(config.CipherSuites) != 1 || config.ClientAuth != tls.RequireAndVerifyClientCert || config.ClientCAs == nil {
+		t.Error(fmt.Errorf("Expected TLS config to follow the HTTPS options, got: %#v", config))
+	}
+
+	commonName := func() string {
+		cert, err := config.GetCertificate(&tls.ClientHelloInfo{})
+		if err != nil {
+			t.Error(err)
+			return ""
+		}
+		parsed, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Error(err)
+			return ""
+		}
+		return parsed.Subject.CommonName
+	}
+	if name := commonName(); name != "first.example.com" {
+		t.Error(fmt.Errorf("Expected initial certificate to be served, got: %s", name))
+	}
+
+	// Replace the certificate, making sure that its modification time changes
+	if _, _, err := writeCert(dir, "second.example.com"); err != nil {
+		t.Error(err)
+		return
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	if name := commonName(); name != "first.example.com" {
+		t.Error(fmt.Errorf("Expected files to not be checked again right away, got: %s", name))
+	}
+	defer func(interval time.Duration) {
+		certCheckInterval = interval
+	}(certCheckInterval)
+	certCheckInterval = 0
+	if name := commonName(); name != "second.example.com" {
+		t.Error(fmt.Errorf("Expected certificate to be reloaded, got: %s", name))
+	}
+
+	// A broken certificate keeps the previous one in use
+	ioutil.WriteFile(keyFile, []byte("broken"), 0600)
+	if name := commonName(); name != "second.example.com" {
+		t.Error(fmt.Errorf("Expected previous certificate to be kept, got: %s", name))
+	}
+
+	res := httptest.NewRecorder()
+	p.redirectToHTTPS(res, httptest.NewRequest("GET", "/", nil))
+	if hsts := res.Header().Get("Strict-Transport-Security"); hsts != "max-age=31536000; includeSubDomains" {
+		t.Error(fmt.Errorf("Expected redirect to send HSTS header, got: %s", hsts))
+	}
+	res = httptest.NewRecorder()
+	p.server.Handler.ServeHTTP(res, httptest.NewRequest("GET", "/", nil))
+	if hsts := res.Header().Get("Strict-Transport-Security"); hsts != "" {
+		t.Error(fmt.Errorf("Expected plain HTTP responses to not send HSTS header, got: %s", hsts))
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.TLS = &tls.ConnectionState{}
+	res = httptest.NewRecorder()
+	p.server.Handler.ServeHTTP(res, req)
+	if hsts := res.Header().Get("Strict-Transport-Security"); hsts == "" {
+		t.Error(fmt.Errorf("Expected HTTPS responses to send HSTS header"))
+	}
+}
@@ -0,0 +1,75 @@
+package patrol
+
+import (
+	"time"
+
+	"github.com/karimsa/patrol/internal/history"
+	"github.com/karimsa/patrol/internal/uptime"
+)
+
+// Uptime of a single group (service) and each of its checks, with one
+// result per requested window.
+type GroupUptime struct {
+	Service []uptime.Result
+	Checks  map[string][]uptime.Result
+}
+
+// Uptime calculates the uptime of every group and check over each of the
+// given windows. If no windows are given, the configured windows are used.
+func (p *Patrol) Uptime(windows []time.Duration) map[string]GroupUptime {
+	if len(windows) == 0 {
+		windows = p.uptimeWindows
+	}
+	return uptimeOf(p.History.GetData(), windows)
+}
+
+// Calculates the uptime of the given history data over each window.
+func uptimeOf(data map[string]map[string][]history.Item, windows []time.Duration) map[string]GroupUptime {
+	now := time.Now()
+	result := make(map[string]GroupUptime, len(data))
+	for groupName, group := range data {
+		groupUptime := GroupUptime{
+			Service: make([]uptime.Result, len(windows)),
+			Checks:  make(map[string][]uptime.Result, len(group)),
+		}
+		for idx, window := range windows {
+			groupUptime.Service[idx] = uptime.ForService(group, window, now)
+		}
+		for checkName, items := range group {
+			results := make([]uptime.Result, len(windows))
+			for idx, window := range windows {
+				results[idx] = uptime.ForCheck(items, window, now)
+			}
+			groupUptime.Checks[checkName] = results
+		}
+		result[groupName] = groupUptime
+	}
+	return result
+}
+
+// Serializes uptime results as a map of window to percentage, with unknown
+// values set to null.
+func uptimeJSON(results []uptime.Result) map[string]*float64 {
+	data := make(map[string]*float64, len(results))
+	for _, r := range results {
+		var percent *float64
+		if r.Known() {
+			p := r.Percent()
+			percent = &p
+		}
+		data[uptime.FormatWindow(r.Window)] = percent
+	}
+	return data
+}
+
+// Serializes the windows that the data does not fully cover as a map of
+// window to the number of seconds that it does cover.
+func coverageJSON(results []uptime.Result) map[string]float64 {
+	data := make(map[string]float64)
+	for _, r := range results {
+		if r.Partial() {
+			data[uptime.FormatWindow(r.Window)] = r.Covered.Seconds()
+		}
+	}
+	return data
+}
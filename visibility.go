@@ -0,0 +1,205 @@
+package patrol
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/karimsa/patrol/internal/announcement"
+	"github.com/karimsa/patrol/internal/auth"
+	"github.com/karimsa/patrol/internal/history"
+	"github.com/karimsa/patrol/internal/incident"
+	"github.com/karimsa/patrol/internal/silence"
+)
+
+// Requests without credentials and requests for public pages are given the
+// public view, which only shows public checks without any of their hidden
+// details.
+func isPublicView(req *http.Request) bool {
+	if page := pageOf(req); page != nil && page.Visibility == "public" {
+		return true
+	}
+	return auth.FromContext(req.Context()).Anonymous()
+}
+
+// Returns true if the request cannot see every check.
+func isRestricted(req *http.Request) bool {
+	if page := pageOf(req); page != nil && len(page.Services) > 0 {
+		return true
+	}
+	return isPublicView(req)
+}
+
+// Returns true if the check is visible to the request. Checks that are no
+// longer configured (but are still in the history) are hidden from the
+// public view.
+func (p *Patrol) canSee(req *http.Request, group, check string) bool {
+	if page := pageOf(req); page != nil && !page.hasService(group) {
+		return false
+	}
+	if !isPublicView(req) {
+		return true
+	}
+	c := p.getChecker(group, check)
+	return c != nil && c.Public()
+}
+
+// Returns true if any check of the group is visible to the request.
+func (p *Patrol) canSeeGroup(req *http.Request, group string) bool {
+	for _, c := range p.checkers {
+		if c.Group == group && p.canSee(req, c.Group, c.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the output and errors of the check are hidden from the
+// request.
+func (p *Patrol) hidesOutput(req *http.Request, group, check string) bool {
+	if !isPublicView(req) {
+		return false
+	}
+	c := p.getChecker(group, check)
+	return c == nil || c.HideOutput
+}
+
+// Returns the item without its output and error, if those are hidden from
+// the request.
+func (p *Patrol) sanitizeItem(req *http.Request, item history.Item) history.Item {
+	if p.hidesOutput(req, item.Group, item.Name) {
+		item.Output = nil
+		item.Error = ""
+	}
+	return item
+}
+
+// Returns the items of a single check as they should be seen by the request.
+func (p *Patrol) sanitizeItems(req *http.Request, items []history.Item) []history.Item {
+	if len(items) == 0 || !p.hidesOutput(req, items[0].Group, items[0].Name) {
+		return items
+	}
+	sanitized := make([]history.Item, len(items))
+	for idx, item := range items {
+		sanitized[idx] = p.sanitizeItem(req, item)
+	}
+	return sanitized
+}
+
+// Returns the history data that is visible to the request.
+func (p *Patrol) visibleData(req *http.Request) map[string]map[string][]history.Item {
+	data := p.History.GetData()
+	if !isRestricted(req) {
+		return data
+	}
+	for groupName, group := range data {
+		for checkName, items := range group {
+			if p.canSee(req, groupName, checkName) {
+				group[checkName] = p.sanitizeItems(req, items)
+			} else {
+				delete(group, checkName)
+			}
+		}
+		if len(group) == 0 {
+			delete(data, groupName)
+		}
+	}
+	return data
+}
+
+// Returns the incident as it should be seen by the request, or false if it
+// is not visible at all.
+func (p *Patrol) visibleIncident(req *http.Request, i incident.Incident) (incident.Incident, bool) {
+	if !p.canSee(req, i.Group, i.Check) {
+		return i, false
+	}
+	if p.hidesOutput(req, i.Group, i.Check) {
+		i.FirstError = ""
+	}
+	return i, true
+}
+
+// Returns the incidents that are visible to the request.
+func (p *Patrol) visibleIncidents(req *http.Request, incidents []incident.Incident) []incident.Incident {
+	if !isRestricted(req) {
+		return incidents
+	}
+	visible := make([]incident.Incident, 0, len(incidents))
+	for _, i := range incidents {
+		if i, ok := p.visibleIncident(req, i); ok {
+			visible = append(visible, i)
+		}
+	}
+	return visible
+}
+
+// Returns true if the silence matches any check that is visible to the
+// request. Silences that only match internal checks would otherwise reveal
+// their names or labels.
+func (p *Patrol) canSeeSilence(req *http.Request, s silence.Silence) bool {
+	if !isRestricted(req) {
+		return true
+	}
+	for _, c := range p.checkers {
+		if s.Matches(c.Group, c.Name, p.getLabels(c.Group, c.Name)) && p.canSee(req, c.Group, c.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the silences that are visible to the request.
+func (p *Patrol) visibleSilences(req *http.Request, silences []silence.Silence) []silence.Silence {
+	if !isRestricted(req) {
+		return silences
+	}
+	visible := make([]silence.Silence, 0, len(silences))
+	for _, s := range silences {
+		if p.canSeeSilence(req, s) {
+			visible = append(visible, s)
+		}
+	}
+	return visible
+}
+
+// Returns the uptime of the checks that are visible to the request.
+func (p *Patrol) visibleUptime(req *http.Request, windows []time.Duration) map[string]GroupUptime {
+	if !isRestricted(req) {
+		return p.Uptime(windows)
+	}
+	if len(windows) == 0 {
+		windows = p.uptimeWindows
+	}
+	return uptimeOf(p.visibleData(req), windows)
+}
+
+// Returns the announcement as it should be seen by the request, or false if
+// it is not visible at all. Announcements without any services are shown on
+// every page. Otherwise, only the services that the request can see are
+// listed, and announcements about none of them are hidden.
+func (p *Patrol) visibleAnnouncement(req *http.Request, a announcement.Announcement) (announcement.Announcement, bool) {
+	if !isRestricted(req) || len(a.Services) == 0 {
+		return a, true
+	}
+	services := make([]string, 0, len(a.Services))
+	for _, service := range a.Services {
+		if p.canSeeGroup(req, service) {
+			services = append(services, service)
+		}
+	}
+	a.Services = services
+	return a, len(services) > 0
+}
+
+// Returns the announcements that are visible to the request.
+func (p *Patrol) visibleAnnouncements(req *http.Request, announcements []announcement.Announcement) []announcement.Announcement {
+	if !isRestricted(req) {
+		return announcements
+	}
+	visible := make([]announcement.Announcement, 0, len(announcements))
+	for _, a := range announcements {
+		if a, ok := p.visibleAnnouncement(req, a); ok {
+			visible = append(visible, a)
+		}
+	}
+	return visible
+}